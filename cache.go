@@ -5,9 +5,12 @@
 package schema
 
 import (
+	"encoding"
 	"errors"
+	"fmt"
 	"maps"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -39,8 +42,15 @@ type cache struct {
 	// regconv holds the registered converters as an immutable map published
 	// atomically: registerConverter replaces the whole map (copy-on-write
 	// under l), so readers never touch a map that is being written.
-	regconv atomic.Pointer[map[reflect.Type]Converter]
+	regconv atomic.Pointer[map[reflect.Type]ConverterE]
 	tag     string
+	// enums describes the enum types registered with RegisterEnum.
+	enums map[reflect.Type]Enum
+	// caseSensitive makes aliases match keys exactly instead of ignoring
+	// case.
+	caseSensitive bool
+	// interfaces enables decoding into empty interface fields.
+	interfaces bool
 	// gen is bumped (under l) before m is cleared on configuration changes;
 	// cached entries are tagged with the generation they were built under
 	// and ignored on mismatch, so any call starting after a reconfiguration
@@ -58,16 +68,36 @@ type cacheEntry struct {
 }
 
 // registerConverter registers a converter function for a custom type.
-func (c *cache) registerConverter(value interface{}, converterFunc Converter) {
+func (c *cache) registerConverter(t reflect.Type, converterFunc ConverterE) {
 	c.l.Lock()
-	next := make(map[reflect.Type]Converter)
+	c.storeConverter(t, converterFunc)
+	delete(c.enums, t)
+	c.reset()
+	c.l.Unlock()
+}
+
+// registerEnum registers converterFunc as the converter of the enum type
+// described by enum.
+func (c *cache) registerEnum(enum Enum, converterFunc ConverterE) {
+	c.l.Lock()
+	c.storeConverter(enum.Type, converterFunc)
+	if c.enums == nil {
+		c.enums = make(map[reflect.Type]Enum)
+	}
+	c.enums[enum.Type] = enum
+	c.reset()
+	c.l.Unlock()
+}
+
+// storeConverter publishes a copy of the registered converters with
+// converterFunc for t. Must be called with l held.
+func (c *cache) storeConverter(t reflect.Type, converterFunc ConverterE) {
+	next := make(map[reflect.Type]ConverterE)
 	if prev := c.regconv.Load(); prev != nil {
 		maps.Copy(next, *prev)
 	}
-	next[reflect.TypeOf(value)] = converterFunc
+	next[t] = converterFunc
 	c.regconv.Store(&next)
-	c.reset()
-	c.l.Unlock()
 }
 
 // parsePath parses a path in dotted notation verifying that it is a valid
@@ -98,6 +128,11 @@ func (c *cache) parsePathInfo(p string, rootInfo *structInfo) ([]pathPart, error
 	var index64 int64
 	var parts []pathPart
 	var hops []pathHop
+	var anyPath string
+	// hasMapKey marks paths that address a map entry: their keys are
+	// client-chosen, so caching them would let requests grow the path cache
+	// without bound.
+	hasMapKey := false
 	for keyStart := 0; ; {
 		keyEnd, segment, err := nextPathSegment(p, keyStart)
 		if err != nil {
@@ -109,10 +144,13 @@ func (c *cache) parsePathInfo(p string, rootInfo *structInfo) ([]pathPart, error
 		// Valid field. Append the hop; the field's index chain was resolved
 		// when the structInfo was built, so the decoder walks plain indices
 		// instead of repeating FieldByName lookups on every Decode call.
-		hops = append(hops, pathHop{index: field.index, ensure: struc.anonymousPtrFields})
-		if field.isSliceOfStructs && !field.isMultipart && (!field.unmarshalerInfo.IsValid || (field.unmarshalerInfo.IsValid && field.unmarshalerInfo.IsSliceElement)) {
-			// Parse a special case: slices of structs.
-			// i+1 must be the slice index.
+		hops = append(hops, pathHop{field: field, index: field.index, ensure: struc.anonymousPtrFields})
+		if (field.isSliceOfStructs && !field.isMultipart && (!field.unmarshalerInfo.IsValid || (field.unmarshalerInfo.IsValid && field.unmarshalerInfo.IsSliceElement))) ||
+			(field.isArray && keyEnd != len(p)) {
+			// Parse a special case: slices (or arrays) of structs.
+			// i+1 must be the slice index. Arrays of other types may be
+			// addressed by index too ("a.1"), or filled from all values of
+			// the plain key.
 			//
 			// Now that struct can implements TextUnmarshaler interface,
 			// we don't need to force the struct's fields to appear in the path.
@@ -145,12 +183,56 @@ func (c *cache) parsePathInfo(p string, rootInfo *structInfo) ([]pathPart, error
 			} else {
 				t = field.typ
 			}
-			if t.Kind() == reflect.Slice {
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
 				t = t.Elem()
 				if t.Kind() == reflect.Ptr {
 					t = t.Elem()
 				}
 			}
+		} else if field.isMap {
+			// Parse map entries: the next segment is the map key. Maps of
+			// scalars or slices end the path there, so the rest of the path
+			// (dots included) is the key; maps of structs continue into the
+			// struct with the following segment.
+			keyStart = keyEnd + 1
+			if keyStart >= len(p) {
+				return nil, errInvalidPath
+			}
+			if field.isMapOfStructs {
+				keyEnd, segment, err = nextPathSegment(p, keyStart)
+				if err != nil {
+					return nil, errInvalidPath
+				}
+			} else {
+				keyEnd, segment = len(p), p[keyStart:]
+			}
+			// Detach the key: it ends up stored in the decoded map.
+			parts = append(parts, pathPart{
+				hops:   hops,
+				field:  field,
+				index:  -1,
+				mapKey: strings.Clone(segment),
+			})
+			hops = nil
+			hasMapKey = true
+			t = indirectType(indirectType(field.typ).Elem())
+		} else if field.isInterface {
+			// Interface fields end the path: the rest of it is the path of
+			// the value in nested maps.
+			if keyEnd != len(p) {
+				anyPath = p[keyEnd+1:]
+				if anyPath == "" || anyPath[0] == '.' || anyPath[len(anyPath)-1] == '.' || strings.Contains(anyPath, "..") {
+					return nil, errInvalidPath
+				}
+				hasMapKey = true
+			}
+			break
+		} else if field.wrapsValue() {
+			// Optional and database/sql Null fields end the path.
+			if keyEnd != len(p) {
+				return nil, errInvalidPath
+			}
+			break
 		} else if field.typ.Kind() == reflect.Ptr {
 			t = field.typ.Elem()
 		} else {
@@ -170,14 +252,21 @@ func (c *cache) parsePathInfo(p string, rootInfo *structInfo) ([]pathPart, error
 		struc = c.get(t)
 	}
 	// Add the remaining. A part without hops means the path terminated at a
-	// slice index ("a.0"), so the decoder receives a slice element there.
+	// slice index ("a.0") or a map key ("a.key"), so the decoder receives a
+	// slice element or map value there.
 	parts = append(parts, pathPart{
-		hops:  hops,
-		field: field,
-		index: -1,
-		elem:  len(hops) == 0,
+		hops:     hops,
+		field:    field,
+		index:    -1,
+		elem:     len(hops) == 0 && !field.isMap,
+		mapValue: len(hops) == 0 && field.isMap,
+		anyPath:  strings.Clone(anyPath),
 	})
 
+	if hasMapKey {
+		return parts, nil
+	}
+
 	// Detach the key: callers may pass strings aliasing reused request buffers.
 	if cached, loaded := rootInfo.paths.LoadOrStore(strings.Clone(p), parts); loaded {
 		return cached.([]pathPart), nil
@@ -251,15 +340,42 @@ func (c *cache) aliasTag() string {
 	return tag
 }
 
+// setInterfaces sets whether empty interface fields are decoded.
+func (c *cache) setInterfaces(b bool) {
+	c.l.Lock()
+	c.interfaces = b
+	c.reset()
+	c.l.Unlock()
+}
+
+// decodesInterfaces reports whether empty interface fields are decoded.
+func (c *cache) decodesInterfaces() bool {
+	c.l.RLock()
+	b := c.interfaces
+	c.l.RUnlock()
+	return b
+}
+
+// setCaseSensitive sets whether aliases match keys exactly.
+func (c *cache) setCaseSensitive(b bool) {
+	c.l.Lock()
+	c.caseSensitive = b
+	c.reset()
+	c.l.Unlock()
+}
+
 // create creates a structInfo with meta-data about a struct.
 func (c *cache) create(t reflect.Type, parentAlias string) *structInfo {
 	// Snapshot the alias tag once so every field of this type is analyzed
 	// under a consistent configuration and we don't churn the config lock
 	// per field.
 	tag := c.aliasTag()
-	info := &structInfo{}
+	c.l.RLock()
+	info := &structInfo{caseSensitive: c.caseSensitive}
+	c.l.RUnlock()
 	var anonymousInfos []*structInfo
 	var anonymousIdx [][]int
+	var anonymousNames []string
 	for i := 0; i < t.NumField(); i++ {
 		structField := t.Field(i)
 		// Only exported anonymous pointers can be allocated; unexported ones
@@ -273,38 +389,112 @@ func (c *cache) create(t reflect.Type, parentAlias string) *structInfo {
 			if ft := indirectType(f.typ); ft.Kind() == reflect.Struct && f.isAnonymous {
 				anonymousInfos = append(anonymousInfos, c.create(ft, f.canonicalAlias))
 				anonymousIdx = append(anonymousIdx, structField.Index)
+				anonymousNames = append(anonymousNames, structField.Name)
 			}
 		}
 	}
+	var promoted []*fieldInfo
 	for i, a := range anonymousInfos {
-		others := []*structInfo{info}
-		others = append(others, anonymousInfos[:i]...)
-		others = append(others, anonymousInfos[i+1:]...)
 		for _, f := range a.fields {
-			if !containsAlias(others, f.alias) {
-				// Copy the promoted field so its index chain can be prefixed
-				// with the embedded field's index; the original stays valid
-				// for the embedded type's own structInfo.
-				pf := *f
-				pf.index = append(append(make([]int, 0, len(anonymousIdx[i])+len(f.index)), anonymousIdx[i]...), f.index...)
-				info.fields = append(info.fields, &pf)
-			}
+			// Copy the promoted field so its index chain can be prefixed
+			// with the embedded field's index; the original stays valid
+			// for the embedded type's own structInfo.
+			pf := *f
+			pf.index = append(append(make([]int, 0, len(anonymousIdx[i])+len(f.index)), anonymousIdx[i]...), f.index...)
+			pf.goPath = anonymousNames[i] + "." + f.goPath
+			promoted = append(promoted, &pf)
 		}
 	}
-	info.fieldsByName = make(map[string]*fieldInfo, len(info.fields))
+	info.resolveAliases(t, promoted)
+	for _, a := range anonymousInfos {
+		info.conflicts = append(info.conflicts, a.conflicts...)
+	}
 	for _, field := range info.fields {
-		if _, exists := info.fieldsByName[field.aliasLower]; !exists {
-			info.fieldsByName[field.aliasLower] = field
+		// Promoted fields were resolved against their own struct.
+		if len(field.index) == 1 {
+			c.resolveRequiredConds(info, field)
 		}
 	}
 	info.requiredFields = c.buildRequiredFields(info)
+	info.requiredSlices = c.buildRequiredSlices(info, tag)
 	// The setDefaults walk also allocates nil anonymous embedded pointers,
 	// so it can only be skipped when neither defaults nor such pointers
 	// exist anywhere in the tree.
 	info.needsDefaultsWalk = c.needsDefaultsWalk(t, tag, map[reflect.Type]bool{})
+	info.hasRules = c.hasRules(t, tag, map[reflect.Type]bool{})
+	info.hasValidators = hasValidators(t, map[reflect.Type]bool{})
 	return info
 }
 
+// prepare reports the tag errors of the fields of info and of the structs
+// nested in it, keyed by goPrefix and their Go path. When warm is set, info
+// is reachable from root without an index or map key, at the form path
+// prefix, and the paths of its fields are parsed to cache them. visited
+// holds the types being walked, guarding against recursive types.
+func (c *cache) prepare(root, info *structInfo, prefix, goPrefix string, warm bool, visited map[reflect.Type]bool, errs MultiError) MultiError {
+	for _, conflict := range info.conflicts {
+		errs = appendError(errs, goPrefix+conflict.Alias, conflict)
+	}
+	for _, f := range info.fields {
+		if f.isAnonymous && indirectType(f.typ).Kind() == reflect.Struct {
+			// Promoted fields are reported through their copies in info.
+			continue
+		}
+		goPath := goPrefix + f.goPath
+		errs = appendError(errs, goPath, f.defaultErr)
+		if f.rules != nil {
+			errs = appendError(errs, goPath, f.rules.err)
+		}
+		for i := range f.requiredConds {
+			errs = appendError(errs, goPath, f.requiredConds[i].err)
+		}
+		if warm {
+			for _, p := range f.paths(prefix) {
+				_, _ = c.parsePathInfo(p, root)
+			}
+		}
+		elem := f.nestedStruct()
+		if elem == nil || visited[elem] {
+			continue
+		}
+		visited[elem] = true
+		// Only nested structs are reachable without an index or a key.
+		nestedWarm := warm && f.isStruct
+		errs = c.prepare(root, c.get(elem), prefix+f.canonicalAlias+".", goPath+".", nestedWarm, visited, errs)
+		delete(visited, elem)
+	}
+	return errs
+}
+
+// aliasConflicts returns the alias conflicts of the struct tree of info, of
+// type t, keyed like those reported by Decoder.Prepare. They are collected
+// once per structInfo.
+func (c *cache) aliasConflicts(t reflect.Type, info *structInfo) MultiError {
+	info.conflictsOnce.Do(func() {
+		info.treeConflicts = c.collectConflicts(info, t.String()+".", map[reflect.Type]bool{t: true}, nil)
+	})
+	return info.treeConflicts
+}
+
+func (c *cache) collectConflicts(info *structInfo, goPrefix string, visited map[reflect.Type]bool, errs MultiError) MultiError {
+	for _, conflict := range info.conflicts {
+		errs = appendError(errs, goPrefix+conflict.Alias, conflict)
+	}
+	for _, f := range info.fields {
+		if f.isAnonymous && indirectType(f.typ).Kind() == reflect.Struct {
+			continue
+		}
+		elem := f.nestedStruct()
+		if elem == nil || visited[elem] {
+			continue
+		}
+		visited[elem] = true
+		errs = c.collectConflicts(c.get(elem), goPrefix+f.goPath+".", visited, errs)
+		delete(visited, elem)
+	}
+	return errs
+}
+
 // needsDefaultsWalk reports whether the setDefaults walk can have any effect
 // on the struct tree rooted at t: it declares a default tag option, or has an
 // (exported) anonymous pointer field the walk allocates, anywhere in the
@@ -332,7 +522,12 @@ func (c *cache) needsDefaultsWalk(t reflect.Type, tag string, visited map[reflec
 		if options.getDefaultOptionValue() != "" {
 			return true
 		}
-		if c.needsDefaultsWalk(field.Type, tag, visited) {
+		ft := field.Type
+		if ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array {
+			// The walk also sets the defaults of slice elements.
+			ft = ft.Elem()
+		}
+		if c.needsDefaultsWalk(ft, tag, visited) {
 			return true
 		}
 	}
@@ -351,15 +546,43 @@ func (c *cache) createField(field reflect.StructField, parentAlias, tag string)
 	if parentAlias != "" {
 		canonicalAlias = parentAlias + "." + alias
 	}
+	// Optional and database/sql Null fields are analyzed as fields of the
+	// type of their value.
+	typ := field.Type
+	isOptional := isOptionalType(typ)
+	isSQLNull := !isOptional && isSQLNullType(typ) && c.converter(typ) == nil
+	if isOptional {
+		typ = typ.Field(optionalValue).Type
+	} else if isSQLNull {
+		typ = typ.Field(sqlNullValue).Type
+	}
 	// Check if the type is supported and don't cache it if not.
 	// First let's get the basic type.
-	isSlice, isStruct := false, false
-	ft := field.Type
+	isSlice, isStruct, isMap := false, false, false
+	isInterface := typ.Kind() == reflect.Interface && typ.NumMethod() == 0 && c.decodesInterfaces()
+	ft := typ
 	m := isTextUnmarshaler(reflect.Zero(ft))
 	if ft.Kind() == reflect.Ptr {
 		ft = ft.Elem()
 	}
-	if isSlice = ft.Kind() == reflect.Slice; isSlice {
+	// Maps without a registered converter or an UnmarshalText method are
+	// filled entry by entry from "alias.key" paths; the key type must be
+	// convertible and the value type is analyzed like a field's type.
+	var mapU unmarshaler
+	if ft.Kind() == reflect.Map && !m.IsValid && c.converter(ft) == nil {
+		if !c.isConvertible(ft.Key()) {
+			return nil
+		}
+		isMap = true
+		ft = ft.Elem()
+		mapU = isTextUnmarshaler(reflect.Zero(indirectType(ft)))
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+	}
+	// Arrays are handled like slices, only with a fixed length.
+	isArray := ft.Kind() == reflect.Array
+	if isSlice = ft.Kind() == reflect.Slice || isArray; isSlice {
 		ft = ft.Elem()
 		if ft.Kind() == reflect.Ptr {
 			ft = ft.Elem()
@@ -371,18 +594,24 @@ func (c *cache) createField(field reflect.StructField, parentAlias, tag string)
 			ft = ft.Elem()
 		}
 	}
-	if isStruct = ft.Kind() == reflect.Struct; !isStruct {
-		if c.converter(ft) == nil && getBuiltinConverter(ft.Kind()) == nil {
+	// time.Time is decoded as a single value.
+	if isStruct = ft.Kind() == reflect.Struct && ft != timeType; !isStruct {
+		if !c.isConvertible(ft) && ft != timeType && !isInterface {
 			// Type is not supported.
 			return nil
 		}
 	}
+	if isMap && isSlice && isStruct && c.converter(ft) == nil {
+		// Slices of structs inside maps would need a second index level
+		// ("a.key.0.b"), which paths don't support.
+		return nil
+	}
 
 	// Reuse the unmarshaler facts when the successive type unwrappings land
 	// on the same type (the common non-pointer, non-slice case).
-	derefT := indirectType(field.Type)
+	derefT := indirectType(typ)
 	derefU := m
-	if derefT != field.Type {
+	if derefT != typ {
 		derefU = isTextUnmarshaler(reflect.Zero(derefT))
 	}
 	elemU := derefU
@@ -390,25 +619,151 @@ func (c *cache) createField(field reflect.StructField, parentAlias, tag string)
 		elemU = isTextUnmarshaler(reflect.Zero(ft))
 	}
 
-	return &fieldInfo{
+	tagName, _ := parseTag(field.Tag.Get(tag))
+	info := &fieldInfo{
 		typ:              field.Type,
 		name:             field.Name,
+		goPath:           field.Name,
 		alias:            alias,
 		aliasLower:       utilstrings.ToLower(alias),
+		tagged:           tagName != "",
 		canonicalAlias:   canonicalAlias,
 		unmarshalerInfo:  m,
 		derefUnmarshaler: derefU,
 		elemUnmarshaler:  elemU,
-		isMultipart:      isMultipartField(field.Type),
-		isSliceOfStructs: isSlice && isStruct,
+		isMultipart:      isMultipartField(typ),
+		isInterface:      isInterface,
+		isOptional:       isOptional,
+		isSQLNull:        isSQLNull,
+		isStruct:         isStruct && !isSlice && !isMap && !derefU.IsValid && c.converter(derefT) == nil,
+		isSliceOfStructs: isSlice && isStruct && !isMap,
+		isArray:          isArray && !isMap && !isOptional && !isSQLNull && !derefU.IsValid && c.converter(derefT) == nil,
+		isMap:            isMap,
+		isMapOfStructs:   isMap && isStruct && !mapU.IsValid && c.converter(ft) == nil,
+		mapUnmarshaler:   mapU,
 		isAnonymous:      field.Anonymous,
 		isRequired:       options.Contains("required"),
 		defaultValue:     options.getDefaultOptionValue(),
+		layout:           options.getLayoutOptionValue(),
+		rules:            compileRules(options, typ),
+		requiredConds:    parseRequiredConds(options),
+	}
+	if isOptional && (info.isStruct || info.isSliceOfStructs && !elemU.IsValid || isMap || isInterface) {
+		// Optional values are decoded from the values of a single key.
+		return nil
+	}
+	if isSQLNull && (info.isStruct || isSlice || isMap || isInterface) {
+		// database/sql Null values are scalars.
+		return nil
+	}
+	if info.defaultValue != "" {
+		info.defaultVal, info.defaultErr = c.parseDefault(info)
+	}
+	return info
+}
+
+// errDefaultUnsupported is reported for default options on fields of types
+// without a conversion from a string.
+var errDefaultUnsupported = errors.New("default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices")
+
+// parseDefault converts the default option value of f once, when its struct
+// is analyzed. The value has the type of f, or of its element for pointers,
+// and slice items are separated by "|". Fields of types with a registered
+// converter or implementing encoding.TextUnmarshaler are supported, as are
+// times and durations.
+func (c *cache) parseDefault(f *fieldInfo) (reflect.Value, error) {
+	if f.isRequired {
+		return invalidValue, errors.New("required fields cannot have a default value")
+	}
+	t := f.valueType()
+	isPtr := t.Kind() == reflect.Ptr
+	if isPtr {
+		t = t.Elem()
+	}
+	if conv := c.defaultConverter(t, f.layout); conv != nil {
+		v, ok := conv(f.defaultValue)
+		if !ok {
+			return invalidValue, fmt.Errorf("failed setting default: %s is not compatible with field %s type", f.defaultValue, f.name)
+		}
+		return v, nil
+	}
+	if t.Kind() != reflect.Slice || isPtr {
+		return invalidValue, errDefaultUnsupported
+	}
+	conv := c.defaultConverter(t.Elem(), f.layout)
+	if conv == nil {
+		return invalidValue, errDefaultUnsupported
+	}
+	slice := reflect.MakeSlice(t, 0, strings.Count(f.defaultValue, "|")+1)
+	for s := range strings.SplitSeq(f.defaultValue, "|") {
+		v, ok := conv(s)
+		if !ok {
+			return invalidValue, fmt.Errorf("failed setting default: %s is not compatible with field %s type", s, f.name)
+		}
+		slice = reflect.Append(slice, v)
+	}
+	return slice, nil
+}
+
+// defaultConverter returns the conversion of default option values to t,
+// with the precedence of decoding: a registered converter, the time
+// support, encoding.TextUnmarshaler, then the builtin converters. It
+// returns nil when t has no conversion.
+func (c *cache) defaultConverter(t reflect.Type, layout string) func(string) (reflect.Value, bool) {
+	// Converted values have the underlying kind of t, or a type a
+	// registered converter chose; convert them to t itself.
+	convertTo := func(v reflect.Value) (reflect.Value, bool) {
+		if !v.IsValid() || !v.Type().ConvertibleTo(t) {
+			return invalidValue, false
+		}
+		return v.Convert(t), true
+	}
+	switch {
+	case c.converter(t) != nil:
+		conv := c.converter(t)
+		return func(s string) (reflect.Value, bool) {
+			v, err := conv(s)
+			if err != nil {
+				return invalidValue, false
+			}
+			return convertTo(v)
+		}
+	case t == timeType:
+		return func(s string) (reflect.Value, bool) {
+			tm, err := parseTime(layout, s)
+			return reflect.ValueOf(tm), err == nil
+		}
+	case t == durationType:
+		return func(s string) (reflect.Value, bool) {
+			d, err := parseDuration(s)
+			return reflect.ValueOf(d), err == nil
+		}
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return func(s string) (reflect.Value, bool) {
+			p := reflect.New(t)
+			u, _ := reflect.TypeAssert[encoding.TextUnmarshaler](p)
+			if err := u.UnmarshalText([]byte(s)); err != nil {
+				return invalidValue, false
+			}
+			return p.Elem(), true
+		}
+	}
+	if conv := getBuiltinConverter(t.Kind()); conv != nil {
+		return func(s string) (reflect.Value, bool) {
+			return convertTo(conv(s))
+		}
 	}
+	return nil
+}
+
+// isConvertible reports whether values of type t can be converted from a
+// string by a registered or builtin converter.
+func (c *cache) isConvertible(t reflect.Type) bool {
+	return c.converter(t) != nil || getBuiltinConverter(t.Kind()) != nil
 }
 
 // converter returns the converter for a type.
-func (c *cache) converter(t reflect.Type) Converter {
+func (c *cache) converter(t reflect.Type) ConverterE {
 	reg := c.regconv.Load()
 	if reg == nil {
 		return nil
@@ -423,6 +778,10 @@ type structInfo struct {
 	fieldsByName       map[string]*fieldInfo
 	anonymousPtrFields []int
 	requiredFields     map[string][]fieldWithPrefix
+	// requiredSlices lists the slices of structs, here and in nested
+	// structs, whose elements have required fields to check for every
+	// index present in the source.
+	requiredSlices []requiredSlice
 	// paths caches parsed paths rooted at this struct type
 	// (map[string][]pathPart); keys are cloned so they never alias reused
 	// request buffers.
@@ -432,16 +791,44 @@ type structInfo struct {
 	// anonymous embedded pointer field (which the walk allocates) exists
 	// anywhere in the tree, letting the decoder skip the walk otherwise.
 	needsDefaultsWalk bool
+	// hasRules reports whether a validation rule is declared anywhere in
+	// this struct tree, letting the decoder skip the validation walk
+	// otherwise.
+	hasRules bool
+	// hasValidators reports whether a struct of this tree implements
+	// Validator, letting the decoder skip that walk otherwise.
+	hasValidators bool
+	// caseSensitive reports whether fieldsByName is keyed by the exact
+	// aliases rather than their lowercase form.
+	caseSensitive bool
+	// conflicts lists the aliases shared by several fields, here and in
+	// embedded structs, and treeConflicts those of the whole struct tree,
+	// collected on first use.
+	conflicts     []AliasConflictError
+	conflictsOnce sync.Once
+	treeConflicts MultiError
+	// goPathPlan maps the Go paths of the fields to their form paths for
+	// the validator set with SetValidator, built on first use.
+	goPathPlan  *goPathPlan
+	goPathsOnce sync.Once
 }
 
 func (i *structInfo) get(alias string) *fieldInfo {
-	aliasKey := utilstrings.ToLower(alias)
-	if field, ok := i.fieldsByName[aliasKey]; ok {
+	if field, ok := i.fieldsByName[i.nameKey(alias)]; ok {
 		return field
 	}
 	return nil
 }
 
+// nameKey returns the key of alias in fieldsByName: the alias itself when
+// matching is case-sensitive, else its lowercase form.
+func (i *structInfo) nameKey(alias string) string {
+	if i.caseSensitive {
+		return alias
+	}
+	return utilstrings.ToLower(alias)
+}
+
 func (c *cache) buildRequiredFields(info *structInfo) map[string][]fieldWithPrefix {
 	var requiredFields map[string][]fieldWithPrefix
 	for _, field := range info.fields {
@@ -458,7 +845,7 @@ func (c *cache) buildRequiredFields(info *structInfo) map[string][]fieldWithPref
 				}
 			}
 		}
-		if field.isRequired {
+		if field.isRequired || len(field.requiredConds) > 0 {
 			requiredFields = appendRequiredField(requiredFields, field.canonicalAlias,
 				newFieldWithPrefix(field, ""))
 		}
@@ -466,27 +853,196 @@ func (c *cache) buildRequiredFields(info *structInfo) map[string][]fieldWithPref
 	return requiredFields
 }
 
-func containsAlias(infos []*structInfo, alias string) bool {
-	aliasKey := utilstrings.ToLower(alias)
-	for _, info := range infos {
-		if _, ok := info.fieldsByName[aliasKey]; ok {
+// buildRequiredSlices lists the slices of structs of info, and of its nested
+// structs, whose element type declares required fields anywhere in its tree.
+func (c *cache) buildRequiredSlices(info *structInfo, tag string) []requiredSlice {
+	var required []requiredSlice
+	for _, field := range info.fields {
+		if field.typ.Kind() == reflect.Struct {
+			for _, s := range c.get(field.typ).requiredSlices {
+				required = append(required, requiredSlice{
+					key:   field.canonicalAlias + "." + s.key,
+					field: newFieldWithPrefix(s.field.fieldInfo, field.canonicalAlias+"."+s.field.prefix),
+					elem:  s.elem,
+				})
+			}
+		}
+		if field.isSliceOfStructs && !field.elemUnmarshaler.IsValid {
+			elem := structElem(field.typ)
+			if c.hasRequired(elem, tag, map[reflect.Type]bool{}) {
+				required = append(required, requiredSlice{
+					key:   field.canonicalAlias,
+					field: searchFieldWithPrefix(field, ""),
+					elem:  elem,
+				})
+			}
+		}
+	}
+	return required
+}
+
+// hasRequired reports whether a field of the struct tree rooted at t,
+// including the structs of slices and maps, has the required option or a
+// conditional one. visited guards against recursive types.
+func (c *cache) hasRequired(t reflect.Type, tag string, visited map[reflect.Type]bool) bool {
+	t = structElem(t)
+	if t.Kind() != reflect.Struct || visited[t] {
+		return false
+	}
+	visited[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		alias, options := fieldAlias(field, tag)
+		if alias == "-" {
+			continue
+		}
+		if options.Contains("required") || parseRequiredConds(options) != nil || c.hasRequired(field.Type, tag, visited) {
 			return true
 		}
 	}
 	return false
 }
 
+// resolveRequiredConds resolves the sibling fields the conditional required
+// options of field refer to, by alias or by Go name, and converts the values
+// they compare to. Conditions on unknown fields keep an error, reported by
+// checkRequired.
+func (c *cache) resolveRequiredConds(info *structInfo, field *fieldInfo) {
+	for i := range field.requiredConds {
+		cond := &field.requiredConds[i]
+		sibling := info.get(cond.name)
+		if sibling == nil {
+			for _, f := range info.fields {
+				if f.name == cond.name {
+					sibling = f
+					break
+				}
+			}
+		}
+		if sibling == nil {
+			cond.err = fmt.Errorf("schema: %s option of field %s refers to unknown field %q", cond.kind, field.name, cond.name)
+			continue
+		}
+		cond.field = sibling
+		if conv := getBuiltinConverter(indirectType(sibling.valueType()).Kind()); conv != nil {
+			if v := conv(cond.value); v.IsValid() {
+				cond.want = v.Interface()
+				cond.conv = conv
+			}
+		}
+	}
+}
+
+// resolveAliases adds the promoted fields to the fields of i, of type t, and
+// indexes them all by alias, following the dominance rules of encoding/json:
+// among the fields sharing an alias, the least nested ones dominate, and
+// among those a field with an alias tag. Fields sharing an alias are
+// recorded as conflicts. Without a dominant field, the first of the fields
+// of t keeps the alias, while promoted fields are all dropped.
+func (i *structInfo) resolveAliases(t reflect.Type, promoted []*fieldInfo) {
+	var keys []string
+	groups := make(map[string][]*fieldInfo, len(i.fields)+len(promoted))
+	for _, f := range slices.Concat(i.fields, promoted) {
+		key := f.aliasLower
+		if i.caseSensitive {
+			key = f.alias
+		}
+		if groups[key] == nil {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], f)
+	}
+	i.fieldsByName = make(map[string]*fieldInfo, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) == 1 {
+			i.fieldsByName[key] = group[0]
+			if len(group[0].index) > 1 {
+				i.fields = append(i.fields, group[0])
+			}
+			continue
+		}
+		dominant := dominantField(group)
+		conflict := AliasConflictError{Type: t, Alias: group[0].alias}
+		for _, f := range group {
+			conflict.Fields = append(conflict.Fields, f.goPath)
+		}
+		switch {
+		case dominant != nil:
+			conflict.Dominant = dominant.goPath
+			i.fieldsByName[key] = dominant
+			if len(dominant.index) > 1 {
+				i.fields = append(i.fields, dominant)
+			}
+		case len(group[0].index) == 1:
+			i.fieldsByName[key] = group[0]
+		}
+		i.conflicts = append(i.conflicts, conflict)
+	}
+}
+
+// dominantField returns the field of group that dominates the others, or
+// nil when the alias is ambiguous.
+func dominantField(group []*fieldInfo) *fieldInfo {
+	i := dominantIndex(len(group), func(i int) (int, bool) {
+		return len(group[i].index), group[i].tagged
+	})
+	if i < 0 {
+		return nil
+	}
+	return group[i]
+}
+
+// dominantIndex returns the index of the field dominating the n fields
+// sharing an alias, described by their depth and whether their alias is
+// tagged, or -1 when the alias is ambiguous. The Encoder and the Decoder
+// share these rules.
+func dominantIndex(n int, field func(int) (depth int, tagged bool)) int {
+	depth, _ := field(0)
+	for i := 1; i < n; i++ {
+		d, _ := field(i)
+		depth = min(depth, d)
+	}
+	dominant, tagged := -1, -1
+	var nDominant, nTagged int
+	for i := 0; i < n; i++ {
+		d, t := field(i)
+		if d != depth {
+			continue
+		}
+		dominant = i
+		nDominant++
+		if t {
+			tagged = i
+			nTagged++
+		}
+	}
+	switch {
+	case nDominant == 1:
+		return dominant
+	case nTagged == 1:
+		return tagged
+	}
+	return -1
+}
+
 type fieldInfo struct {
 	typ reflect.Type
 	// index is the field index chain relative to the struct type whose
 	// structInfo holds this fieldInfo; promoted fields carry the full chain
 	// through the embedded structs (a copy is made per promotion level).
 	index []int
+	// goPath is the Go path of the field relative to the struct, through
+	// the embedded structs it is promoted from ("Embedded.Name").
+	goPath string
 	// name is the field name in the struct.
 	name  string
 	alias string
 	// aliasLower is the pre-computed lowercase alias for fast lookups.
 	aliasLower string
+	// tagged reports whether the alias is set by the alias tag, which makes
+	// the field dominate untagged fields of the same depth.
+	tagged bool
 	// canonicalAlias is almost the same as the alias, but is prefixed with
 	// an embedded struct field alias in dotted notation if this field is
 	// promoted from the struct.
@@ -510,12 +1066,83 @@ type fieldInfo struct {
 	// multipart file header shapes, precomputed so the decoder can skip the
 	// type comparisons on every other field.
 	isMultipart bool
-	// isSliceOfStructs indicates if the field type is a slice of structs.
+	// isStruct indicates that the field is a struct (or a pointer to one)
+	// whose fields are addressed by path, rather than a struct decoded as a
+	// single value like time.Time.
+	isStruct bool
+	// isSliceOfStructs indicates if the field type is a slice (or an array)
+	// of structs.
 	isSliceOfStructs bool
+	// isArray indicates that the field type is an array decoded element by
+	// element, so its elements may be addressed by index ("a.1").
+	isArray bool
+	// isMap indicates that the field is a map filled entry by entry from
+	// "alias.key" paths.
+	isMap bool
+	// isMapOfStructs indicates that the map's values are structs (or
+	// pointers to structs) whose fields are addressed as "alias.key.field".
+	isMapOfStructs bool
+	// mapUnmarshaler is like derefUnmarshaler but for the map's value type;
+	// the decoder uses it when a path terminates at a map key.
+	mapUnmarshaler unmarshaler
+	// isInterface indicates an empty interface field, holding a string, a
+	// []string or nested map[string]any values.
+	isInterface bool
+	// isOptional indicates an Optional field, analyzed as a field of the
+	// type of its value.
+	isOptional bool
+	// isSQLNull indicates a field of a database/sql Null type without a
+	// registered converter, analyzed as a field of the type of its value.
+	isSQLNull bool
 	// isAnonymous indicates whether the field is embedded in the struct.
 	isAnonymous  bool
 	isRequired   bool
 	defaultValue string
+	// defaultVal is the converted default option value, of the type of the
+	// field or of its element for pointers, and defaultErr the error of an
+	// invalid or unsupported default, reported by Prepare and ignored by
+	// Decode; both are set once when the struct is analyzed.
+	defaultVal reflect.Value
+	defaultErr error
+	// layout is the time layout of the "layout:" tag option, used for
+	// time.Time values.
+	layout string
+	// rules are the validation rules of the field; nil when it has none.
+	rules *fieldRules
+	// requiredConds are the conditional required options of the field,
+	// resolved against its siblings once the struct is analyzed.
+	requiredConds []requiredCond
+}
+
+// wrapsValue reports whether f is an Optional or database/sql Null field,
+// whose value is the first field of its struct.
+func (f *fieldInfo) wrapsValue() bool {
+	return f.isOptional || f.isSQLNull
+}
+
+// valueType returns the type of the values of f: the type of the field, or
+// the type of the value of an Optional or database/sql Null field.
+func (f *fieldInfo) valueType() reflect.Type {
+	switch {
+	case f.isOptional:
+		return f.typ.Field(optionalValue).Type
+	case f.isSQLNull:
+		return f.typ.Field(sqlNullValue).Type
+	}
+	return f.typ
+}
+
+// nestedStruct returns the type of the structs whose fields are addressed
+// through f: a nested struct, or the elements of a slice or map of structs.
+// It returns nil for other fields.
+func (f *fieldInfo) nestedStruct() reflect.Type {
+	switch {
+	case f.isStruct:
+		return indirectType(f.typ)
+	case f.isSliceOfStructs && !f.elemUnmarshaler.IsValid, f.isMapOfStructs:
+		return structElem(f.typ)
+	}
+	return nil
 }
 
 func (f *fieldInfo) paths(prefix string) []string {
@@ -533,14 +1160,24 @@ type pathPart struct {
 	// the decoder's value is then an element of the slice field rather than
 	// the field itself.
 	elem bool
+	// mapKey is the raw key of the map entry this part addresses when field
+	// is a map; the decoder converts it to the map's key type.
+	mapKey string
+	// mapValue marks a terminal part whose path ended at a map key
+	// ("a.key"): the decoder's value is then a value of the map field.
+	mapValue bool
+	// anyPath is the dotted path below an interface field ("a.b" in
+	// "field.a.b") of the value in its nested maps.
+	anyPath string
 }
 
 // pathHop describes one named-field lookup along a path. index is the field
 // index chain relative to the struct at this level (more than one element
 // when the field is promoted from embedded structs), and ensure lists the
 // anonymous pointer fields of that struct which must be allocated before the
-// walk so promoted fields stay reachable.
+// walk so promoted fields stay reachable. field is the field it reaches.
 type pathHop struct {
+	field  *fieldInfo
 	index  []int
 	ensure []int
 }
@@ -594,11 +1231,23 @@ func (o tagOptions) Contains(option string) bool {
 }
 
 func (o tagOptions) getDefaultOptionValue() string {
+	return o.getOptionValue("default:")
+}
+
+// getLayoutOptionValue returns the time layout of the "layout:" option.
+// Layouts cannot contain commas, which separate the options.
+func (o tagOptions) getLayoutOptionValue() string {
+	return o.getOptionValue("layout:")
+}
+
+// getOptionValue returns the value of the first option starting with
+// prefix, or "" when there is none.
+func (o tagOptions) getOptionValue(prefix string) string {
 	if o == "" {
 		return ""
 	}
 	for s := range strings.SplitSeq(string(o), ",") {
-		if value, ok := strings.CutPrefix(s, "default:"); ok {
+		if value, ok := strings.CutPrefix(s, prefix); ok {
 			return value
 		}
 	}
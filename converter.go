@@ -13,6 +13,20 @@ import (
 
 type Converter func(string) reflect.Value
 
+// ConverterE is a Converter that can report why a value failed to convert.
+// A non-nil error, or an invalid reflect.Value, makes the decoder return a
+// ConversionError; the error is kept in its Err field.
+type ConverterE func(string) (reflect.Value, error)
+
+// convert runs the registered converter conv, or the builtin one when conv
+// is nil.
+func convert(conv ConverterE, builtin Converter, s string) (reflect.Value, error) {
+	if conv != nil {
+		return conv(s)
+	}
+	return builtin(s), nil
+}
+
 var (
 	invalidValue = reflect.Value{}
 	boolType     = reflect.Bool
@@ -8,11 +8,15 @@ import (
 	"encoding"
 	"errors"
 	"fmt"
+	"iter"
 	"maps"
 	"mime/multipart"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
+
+	utils "github.com/gofiber/utils/v2"
 )
 
 const (
@@ -40,9 +44,30 @@ type Decoder struct {
 	cache             *cache
 	zeroEmpty         bool
 	ignoreUnknownKeys bool
+	bracketNotation   bool
 	maxSize           int
+	timeLayout        string
+	duplicatePolicy   DuplicatePolicy
+	reportConflicts   bool
+	nullValue         string
+	emptyAsNull       bool
+	validator         func(dst any, paths map[string]string) error
 }
 
+// DuplicatePolicy selects the value Decode uses when a key of a field that
+// holds a single value (not a slice or an array) has several values.
+type DuplicatePolicy int
+
+const (
+	// DuplicateLast uses the last value. This is the default.
+	DuplicateLast DuplicatePolicy = iota
+	// DuplicateFirst uses the first value, ignoring values appended later,
+	// e.g. by a proxy.
+	DuplicateFirst
+	// DuplicateError rejects the key with a DuplicateValueError.
+	DuplicateError
+)
+
 // SetAliasTag changes the tag used to locate custom field aliases.
 // The default tag is "schema".
 func (d *Decoder) SetAliasTag(tag string) {
@@ -64,6 +89,31 @@ func (d *Decoder) ZeroEmpty(z bool) {
 	d.zeroEmpty = z
 }
 
+// SetValidator sets a function Decode calls with the destination after
+// decoding, setting defaults, checking required fields and calling the
+// Validate methods, to plug in a validation engine. paths maps the Go path of
+// every field, like "Items[0].SKU", to its form path, like "items.0.sku". It
+// is planned once per type and may be shared between calls: the validator
+// must not modify it.
+//
+// A returned MultiError keyed by Go paths is merged with its keys translated
+// to form paths (unknown keys are kept); any other error is reported under
+// the key "". Passing nil removes the validator.
+func (d *Decoder) SetValidator(fn func(dst any, paths map[string]string) error) {
+	d.validator = fn
+}
+
+// SetTimeLayout sets the layout used to decode time.Time fields that have no
+// "layout:" tag option.
+//
+// By default, RFC 3339 values (with or without fractional seconds) and the
+// values of the HTML datetime-local ("2006-01-02T15:04"), date
+// ("2006-01-02") and time ("15:04") inputs are accepted. Passing an empty
+// layout restores the default.
+func (d *Decoder) SetTimeLayout(layout string) {
+	d.timeLayout = layout
+}
+
 // IgnoreUnknownKeys controls the behaviour when the decoder encounters unknown
 // keys in the map.
 // If i is true and an unknown field is encountered, it is ignored. This is
@@ -76,7 +126,82 @@ func (d *Decoder) IgnoreUnknownKeys(i bool) {
 	d.ignoreUnknownKeys = i
 }
 
-// MaxSize limits the size of slices for URL nested arrays or object arrays.
+// BracketNotation controls whether keys in bracket notation, as sent by PHP,
+// Rails or jQuery's $.param ("phones[0][label]", "attrs[color]", "tags[]"),
+// are accepted.
+// If b is true, bracket keys are translated to their dotted equivalents
+// ("phones.0.label", "attrs.color", "tags") before decoding; dotted and
+// mixed keys ("phones[0].label") keep working, and values of keys that
+// translate to the same path are merged. Errors are reported under the
+// dotted paths. Aliases containing brackets can't be matched in this mode.
+// If b is false, brackets are plain characters of a key.
+//
+// The default value is false.
+func (d *Decoder) BracketNotation(b bool) {
+	d.bracketNotation = b
+}
+
+// InterfaceFields controls whether fields of type any (interface{}) are
+// decoded. If b is true, such a field gets a string for a key with one value,
+// a []string for a key with several values, and map[string]any values for
+// dotted paths below it: "extra.a.b=1" sets the field to
+// map[string]any{"a": map[string]any{"b": "1"}}. When a path has both a value
+// and nested keys, the nested keys win. If b is false, the fields are
+// ignored, like other unsupported types.
+//
+// The default value is false.
+func (d *Decoder) InterfaceFields(b bool) {
+	d.cache.setInterfaces(b)
+}
+
+// CaseSensitive controls whether keys must match the aliases of fields
+// exactly. If c is true, a key like "Name" doesn't match the alias "name"
+// and is reported as unknown (unless unknown keys are ignored); if c is
+// false, keys match aliases regardless of case.
+//
+// The default value is false.
+func (d *Decoder) CaseSensitive(c bool) {
+	d.cache.setCaseSensitive(c)
+}
+
+// ReportAliasConflicts controls whether Decode fails when fields of the
+// destination struct tree share an alias, directly or through embedded
+// structs. If r is true, Decode returns their AliasConflictErrors, keyed like
+// those of Prepare, without decoding; if r is false, the dominant field, if
+// any, gets the alias.
+//
+// The default value is false.
+func (d *Decoder) ReportAliasConflicts(r bool) {
+	d.reportConflicts = r
+}
+
+// SetNullValue sets the value that decodes to a nil pointer, for pointer
+// fields and the pointer elements of slices. Passing "null", the encoding of
+// nil pointers by the Encoder, makes its output decode back without loss.
+// Passing an empty value, the default, disables it: the value is then
+// decoded like any other.
+func (d *Decoder) SetNullValue(null string) {
+	d.nullValue = null
+}
+
+// EmptyAsNull controls whether an empty value decodes to a nil pointer, for
+// pointer fields and the pointer elements of slices, like the null value.
+//
+// The default value is false.
+func (d *Decoder) EmptyAsNull(e bool) {
+	d.emptyAsNull = e
+}
+
+// SetDuplicatePolicy sets how Decode handles several values for a field that
+// holds a single value: it uses the last one (DuplicateLast, the default),
+// the first one (DuplicateFirst), or reports a DuplicateValueError under the
+// key (DuplicateError), a strict mode for security-sensitive endpoints.
+func (d *Decoder) SetDuplicatePolicy(p DuplicatePolicy) {
+	d.duplicatePolicy = p
+}
+
+// MaxSize limits the size of slices for URL nested arrays or object arrays,
+// and the number of entries a map field can hold.
 // Choose MaxSize carefully; large values may create many zero-value slice elements.
 // Example: "items.100000=apple" would create a slice with 100,000 empty strings.
 func (d *Decoder) MaxSize(size int) {
@@ -85,7 +210,37 @@ func (d *Decoder) MaxSize(size int) {
 
 // RegisterConverter registers a converter function for a custom type.
 func (d *Decoder) RegisterConverter(value interface{}, converterFunc Converter) {
-	d.cache.registerConverter(value, converterFunc)
+	var conv ConverterE
+	if converterFunc != nil {
+		conv = func(s string) (reflect.Value, error) {
+			return converterFunc(s), nil
+		}
+	}
+	d.cache.registerConverter(reflect.TypeOf(value), conv)
+}
+
+// RegisterConverterE registers a converter function for a custom type that
+// reports why a value failed to convert. The error is returned in the Err
+// field of the resulting ConversionError.
+func (d *Decoder) RegisterConverterE(value interface{}, converterFunc ConverterE) {
+	d.cache.registerConverter(reflect.TypeOf(value), converterFunc)
+}
+
+// RegisterConverterFunc registers a typed converter function for T. A
+// non-nil error is returned in the Err field of the resulting
+// ConversionError.
+//
+//	schema.RegisterConverterFunc(decoder, func(s string) (uuid.UUID, error) {
+//		return uuid.Parse(s)
+//	})
+func RegisterConverterFunc[T any](d *Decoder, fn func(string) (T, error)) {
+	d.cache.registerConverter(reflect.TypeFor[T](), func(s string) (reflect.Value, error) {
+		v, err := fn(s)
+		if err != nil {
+			return invalidValue, err
+		}
+		return reflect.ValueOf(&v).Elem(), nil
+	})
 }
 
 // Decode decodes a map[string][]string to a struct.
@@ -96,12 +251,129 @@ func (d *Decoder) RegisterConverter(value interface{}, converterFunc Converter)
 // Keys are "paths" in dotted notation to the struct fields and nested structs.
 //
 // See the package documentation for a full explanation of the mechanics.
-func (d *Decoder) Decode(dst interface{}, src map[string][]string, files ...map[string][]*multipart.FileHeader) (err error) {
+func (d *Decoder) Decode(dst interface{}, src map[string][]string, files ...map[string][]*multipart.FileHeader) error {
 	v := reflect.ValueOf(dst)
 	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
 		return errNotPointerToStruct
 	}
+	return d.decodeStruct(v.Elem(), src, files, nil)
+}
 
+// DecodeResult reports the fields a Decode call wrote, by their canonical
+// paths in dotted notation: the alias of each field, prefixed with the
+// alias of the embedded struct for promoted fields, along with the slice
+// indices and map keys of the keys.
+type DecodeResult struct {
+	// Provided holds the paths of the keys of src that were decoded,
+	// except those counted in Zeroed and the empty values that left their
+	// field unchanged.
+	Provided []string
+	// Defaulted holds the paths of the fields set to their default value.
+	Defaulted []string
+	// Zeroed holds the paths of the keys whose values were all empty and
+	// set the field to its zero value because of ZeroEmpty.
+	Zeroed []string
+
+	fieldMask []string
+}
+
+// FieldMask returns the Go paths of the fields written, like the paths of a
+// protobuf FieldMask: sorted, without duplicates, and ending at the first
+// slice, array or map along the path, since their elements cannot be
+// addressed ("Items" for "items.0.sku").
+func (r *DecodeResult) FieldMask() []string {
+	return slices.Clone(r.fieldMask)
+}
+
+// DecodeWithResult is Decode, also reporting which fields were written: it
+// tells fields absent from src apart from those sent with a zero value, as
+// PATCH handlers need. The result is returned along with the error, holding
+// the fields that decoded successfully.
+//
+//	res, err := decoder.DecodeWithResult(&patch, r.PostForm)
+//	if err == nil {
+//		err = store.Update(id, patch, res.FieldMask())
+//	}
+func (d *Decoder) DecodeWithResult(dst interface{}, src map[string][]string, files ...map[string][]*multipart.FileHeader) (*DecodeResult, error) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, errNotPointerToStruct
+	}
+	res := &DecodeResult{}
+	err := d.decodeStruct(v.Elem(), src, files, res)
+	rootInfo := d.cache.get(v.Elem().Type())
+	for _, list := range [...]*[]string{&res.Provided, &res.Defaulted, &res.Zeroed} {
+		slices.Sort(*list)
+		*list = slices.Compact(*list)
+		for _, path := range *list {
+			if parts, perr := d.cache.parsePathInfo(path, rootInfo); perr == nil {
+				res.fieldMask = append(res.fieldMask, maskPath(parts))
+			}
+		}
+	}
+	slices.Sort(res.fieldMask)
+	res.fieldMask = slices.Compact(res.fieldMask)
+	return res, err
+}
+
+// Prepare analyzes the struct types of types, given as values of or
+// pointers to the structs, ahead of the first Decode: it caches their field
+// information and the paths of their fields, and reports the mistakes in
+// their tags that Decode would otherwise report on every call, such as
+// invalid or unsupported defaults, required fields with a default, invalid
+// validation rules and conditions on unknown fields.
+//
+// The returned MultiError is keyed by the type and the Go path of the
+// fields, like "main.Order.Items.SKU". Call Prepare after configuring the
+// decoder: RegisterConverter and SetAliasTag clear its cache.
+//
+//	if err := decoder.Prepare(SignupForm{}, &OrderForm{}); err != nil {
+//		log.Fatal(err)
+//	}
+func (d *Decoder) Prepare(types ...any) error {
+	var errs MultiError
+	for _, value := range types {
+		t := reflect.TypeOf(value)
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			errs = appendError(errs, fmt.Sprint(t), fmt.Errorf("schema: cannot prepare %v: not a struct", t))
+			continue
+		}
+		info := d.cache.get(t)
+		errs = d.cache.prepare(info, info, "", t.String()+".", true, map[reflect.Type]bool{t: true}, errs)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// DecodeAs decodes a map[string][]string to a new value of type T and
+// returns it. T must be a struct or a pointer to a struct, which is
+// allocated. As with Decode, the value is returned along with the error,
+// holding the fields that decoded successfully.
+//
+//	form, err := schema.DecodeAs[SignupForm](decoder, r.PostForm)
+func DecodeAs[T any](d *Decoder, src map[string][]string, files ...map[string][]*multipart.FileHeader) (T, error) {
+	var dst T
+	v := reflect.ValueOf(&dst).Elem()
+	if v.Kind() == reflect.Ptr {
+		if v.Type().Elem().Kind() != reflect.Struct {
+			return dst, errNotPointerToStruct
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	} else if v.Kind() != reflect.Struct {
+		return dst, errNotPointerToStruct
+	}
+	return dst, d.decodeStruct(v, src, files, nil)
+}
+
+// decodeStruct decodes src into v, an addressable struct, recording the
+// fields written in res when it is not nil.
+func (d *Decoder) decodeStruct(v reflect.Value, src map[string][]string, files []map[string][]*multipart.FileHeader, res *DecodeResult) (err error) {
 	// Catch panics from the decoder and return them as an error.
 	// This is needed because the decoder calls reflect and reflect panics.
 	// Installed before any other work so nothing can crash the caller.
@@ -121,6 +393,11 @@ func (d *Decoder) Decode(dst interface{}, src map[string][]string, files ...map[
 		multipartFiles = files[0]
 	}
 
+	if d.bracketNotation {
+		src = dottedPaths(src)
+		multipartFiles = dottedPaths(multipartFiles)
+	}
+
 	// Add files as empty string values to the decode view so path parsing
 	// works uniformly. Work on a copy: the caller's src map must not be
 	// mutated (and a caller-provided value under a file's key must not be
@@ -134,9 +411,13 @@ func (d *Decoder) Decode(dst interface{}, src map[string][]string, files ...map[
 		src = merged
 	}
 
-	v = v.Elem()
 	t := v.Type()
 	rootInfo := d.cache.get(t)
+	if d.reportConflicts {
+		if conflicts := d.cache.aliasConflicts(t, rootInfo); len(conflicts) > 0 {
+			return maps.Clone(conflicts)
+		}
+	}
 	var multiErrors MultiError
 	for path, values := range src {
 		if parts, err := d.cache.parsePathInfo(path, rootInfo); err == nil {
@@ -146,6 +427,15 @@ func (d *Decoder) Decode(dst interface{}, src map[string][]string, files ...map[
 			}
 			if err = d.decode(v, path, parts, values, filesSlice); err != nil {
 				multiErrors = appendError(multiErrors, path, err)
+			} else if res != nil {
+				switch {
+				case len(filesSlice) > 0 || !allEmpty(values):
+					res.Provided = append(res.Provided, canonicalPath(parts))
+				case d.zeroEmpty:
+					res.Zeroed = append(res.Zeroed, canonicalPath(parts))
+				case d.writesEmpty(parts):
+					res.Provided = append(res.Provided, canonicalPath(parts))
+				}
 			}
 		} else {
 			if errors.Is(err, errIndexTooLarge) {
@@ -155,30 +445,104 @@ func (d *Decoder) Decode(dst interface{}, src map[string][]string, files ...map[
 			}
 		}
 	}
+	elems := &elementIndex{src: src}
 	if rootInfo.needsDefaultsWalk {
-		multiErrors = mergeErrors(multiErrors, d.setDefaults(t, v, src, ""))
+		d.setDefaults(t, v, src, elems, "", res)
+	}
+	multiErrors = mergeErrors(multiErrors, d.checkRequired(rootInfo, src, elems, ""))
+	if rootInfo.hasRules {
+		multiErrors = d.validate(rootInfo, v, src, multiErrors)
+	}
+	if rootInfo.hasValidators {
+		multiErrors = d.callValidators(rootInfo, v, multiErrors)
+	}
+	if d.validator != nil {
+		multiErrors = d.runValidator(rootInfo, v, multiErrors)
 	}
-	multiErrors = mergeErrors(multiErrors, d.checkRequired(rootInfo, src))
 	if len(multiErrors) > 0 {
 		return multiErrors
 	}
 	return nil
 }
 
+// dottedPaths returns m with its bracket-notation keys translated to dotted
+// notation, merging the values of keys that translate to the same path. The
+// caller's map is only copied when it has bracket keys.
+func dottedPaths[V any](m map[string][]V) map[string][]V {
+	var out map[string][]V
+	for key := range m {
+		if strings.IndexByte(key, '[') != -1 {
+			out = make(map[string][]V, len(m))
+			break
+		}
+	}
+	if out == nil {
+		return m
+	}
+	for key, values := range m {
+		path := bracketToDotted(key)
+		out[path] = append(out[path], values...)
+	}
+	return out
+}
+
+// bracketToDotted translates a key in bracket notation to dotted notation:
+// "a[0][b]" becomes "a.0.b", a trailing "[]" is dropped ("tags[]" becomes
+// "tags"), and segments after a closing bracket may also be dotted
+// ("a[0].b"). Malformed keys are returned unchanged, so they are reported as
+// unknown.
+func bracketToDotted(key string) string {
+	i := strings.IndexByte(key, '[')
+	if i <= 0 {
+		return key
+	}
+	var b strings.Builder
+	b.Grow(len(key))
+	b.WriteString(key[:i])
+	for i < len(key) {
+		if key[i] == '[' {
+			j := strings.IndexByte(key[i+1:], ']')
+			if j < 0 {
+				return key
+			}
+			segment := key[i+1 : i+1+j]
+			i += j + 2
+			if segment == "" {
+				// "[]" only marks a multi-valued key at the end.
+				if i != len(key) {
+					return key
+				}
+				continue
+			}
+			b.WriteByte('.')
+			b.WriteString(segment)
+			continue
+		}
+		if key[i] != '.' {
+			return key
+		}
+		j := strings.IndexByte(key[i:], '[')
+		if j < 0 {
+			j = len(key) - i
+		}
+		b.WriteString(key[i : i+j])
+		i += j
+	}
+	return b.String()
+}
+
 // setDefaults sets the default values when the `default` tag is specified,
 // default is supported on basic/primitive types and their pointers,
 // nested structs can also have default tags
-func (d *Decoder) setDefaults(t reflect.Type, v reflect.Value, src map[string][]string, prefix string) MultiError {
+func (d *Decoder) setDefaults(t reflect.Type, v reflect.Value, src map[string][]string, elems *elementIndex, prefix string, res *DecodeResult) {
 	struc := d.cache.get(t)
 	// Skip the walk entirely when it can have no effect (no default tags and
 	// no anonymous embedded pointers to allocate anywhere in the tree) — the
 	// overwhelmingly common case.
 	if !struc.needsDefaultsWalk {
-		return nil
+		return
 	}
 
-	var errs MultiError
-
 	// Allocate nil anonymous embedded pointer fields so their promoted
 	// fields stay reachable.
 	for _, idx := range struc.anonymousPtrFields {
@@ -194,77 +558,262 @@ func (d *Decoder) setDefaults(t reflect.Type, v reflect.Value, src map[string][]
 			continue
 		}
 
-		if vCurrent.Type().Kind() == reflect.Struct && f.defaultValue == "" {
-			errs = mergeErrors(errs, d.setDefaults(vCurrent.Type(), vCurrent, src, prefix+f.canonicalAlias+"."))
+		if vCurrent.Type().Kind() == reflect.Struct && !f.wrapsValue() && f.defaultValue == "" {
+			d.setDefaults(vCurrent.Type(), vCurrent, src, elems, prefix+f.canonicalAlias+".", res)
 		} else if isPointerToStruct(vCurrent) && f.defaultValue == "" {
-			errs = mergeErrors(errs, d.setDefaults(vCurrent.Elem().Type(), vCurrent.Elem(), src, prefix+f.canonicalAlias+"."))
-		}
-
-		if f.defaultValue != "" && f.isRequired {
-			errs = appendError(errs, "default-"+f.name, errors.New("required fields cannot have a default value"))
-		} else if f.defaultValue != "" && vCurrent.IsZero() && !f.isRequired && !fieldProvided(src, prefix, f) {
-			if f.typ.Kind() == reflect.Struct {
-				errs = appendError(errs, "default-"+f.name, errors.New("default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices"))
-			} else if f.typ.Kind() == reflect.Slice {
-				// check if slice has one of the supported types for defaults
-				conv := getBuiltinConverter(f.typ.Elem().Kind())
-				if conv == nil {
-					errs = appendError(errs, "default-"+f.name, errors.New("default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices"))
-					continue
-				}
+			d.setDefaults(vCurrent.Elem().Type(), vCurrent.Elem(), src, elems, prefix+f.canonicalAlias+".", res)
+		} else if f.isSliceOfStructs && !f.elemUnmarshaler.IsValid && f.defaultValue == "" {
+			d.setElemDefaults(f, vCurrent, src, elems, prefix, res)
+		}
 
-				elemT := f.typ.Elem()
-				defaultSlice := reflect.MakeSlice(f.typ, 0, strings.Count(f.defaultValue, "|")+1)
-				for val := range strings.SplitSeq(f.defaultValue, "|") {
-					// this check is to handle if the wrong value is provided
-					convertedVal := conv(val)
-					if !convertedVal.IsValid() {
-						errs = appendError(errs, "default-"+f.name, fmt.Errorf("failed setting default: %s is not compatible with field %s type", val, f.name))
-						break
-					}
-					// Builtin converters return the underlying kind; convert to
-					// the (possibly named) element type before appending, else
-					// reflect.Append panics for e.g. []MyInt.
-					defaultSlice = reflect.Append(defaultSlice, convertedVal.Convert(elemT))
-				}
-				vCurrent.Set(defaultSlice)
-			} else if f.typ.Kind() == reflect.Ptr {
-				t1 := f.typ.Elem()
+		if f.defaultValue == "" {
+			continue
+		}
+		// Invalid defaults have no effect; Prepare reports them.
+		if f.defaultErr == nil && vCurrent.IsZero() && !fieldProvided(src, prefix, f) {
+			value := f.defaultVal
+			if value.Kind() == reflect.Slice {
+				// Never share the backing array of the default between
+				// decoded values.
+				value = reflect.AppendSlice(reflect.MakeSlice(value.Type(), 0, value.Len()), value)
+			}
+			if t := f.valueType(); t.Kind() == reflect.Ptr {
+				// Build a pointer of the field's actual element type: *elem
+				// is assignable to the field even when the field's type is
+				// itself a named pointer type (e.g. type MyIntPtr *MyInt).
+				p := reflect.New(t.Elem())
+				p.Elem().Set(value)
+				value = p
+			}
+			if f.isOptional {
+				vCurrent.Field(optionalValue).Set(value)
+				setOptional(vCurrent, false)
+			} else if f.isSQLNull {
+				vCurrent.Field(sqlNullValue).Set(value)
+				vCurrent.Field(sqlNullValid).SetBool(true)
+			} else {
+				vCurrent.Set(value)
+			}
+			if res != nil {
+				res.Defaulted = append(res.Defaulted, prefix+f.canonicalAlias)
+			}
+		}
+	}
+}
 
-				if t1.Kind() == reflect.Struct || t1.Kind() == reflect.Slice {
-					errs = appendError(errs, "default-"+f.name, errors.New("default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices"))
-				}
+// setElemDefaults sets the default values of the elements of v, the value
+// of the slice of structs field f, whose index is present in src, grouped
+// in elems.
+func (d *Decoder) setElemDefaults(f *fieldInfo, v reflect.Value, src map[string][]string, elems *elementIndex, prefix string, res *DecodeResult) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	paths := f.paths(prefix)
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				continue
+			}
+			elem = elem.Elem()
+		}
+		if !elems.provided(paths, int64(i)) {
+			continue
+		}
+		index := utils.FormatInt(int64(i))
+		d.setDefaults(elem.Type(), elem, src, elems, prefix+f.canonicalAlias+"."+index+".", res)
+	}
+}
 
-				// this check is to handle if the wrong value is provided
-				if conv := getBuiltinConverter(t1.Kind()); conv != nil {
-					if convertedVal := conv(f.defaultValue); convertedVal.IsValid() {
-						// Build a pointer of the field's actual element type:
-						// the converter yields the underlying kind, which is
-						// convertible to the (possibly named) element type,
-						// and *elem is assignable to the field even when the
-						// field's type is itself a named pointer type (e.g.
-						// type MyIntPtr *MyInt), where converting a *int
-						// directly would panic.
-						p := reflect.New(t1)
-						p.Elem().Set(convertedVal.Convert(t1))
-						vCurrent.Set(p)
-					}
+// elementIndex groups the keys of a source addressing the elements of
+// slices, like "items.0.sku", by the path of the slice and the index. It is
+// built on first use, so the required checks and the defaults of all the
+// elements scan the source once per Decode.
+type elementIndex struct {
+	src   map[string][]string
+	elems map[string]map[int64]map[string][]string
+	built bool
+}
+
+// elements returns the source of every element of the slice at path, by
+// index, with the keys relative to the element. It must not be modified.
+func (x *elementIndex) elements(path string) map[int64]map[string][]string {
+	if !x.built {
+		x.build()
+	}
+	return x.elems[path]
+}
+
+// provided reports whether the source has a key for the element at index
+// of the slice known by paths.
+func (x *elementIndex) provided(paths []string, index int64) bool {
+	for _, p := range paths {
+		if _, ok := x.elements(p)[index]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// build groups the keys of the source under every index segment they have
+// followed by more segments: "a.0.b.1.c" is a key "b.1.c" of the element 0
+// of "a", and a key "c" of the element 1 of "a.0.b".
+func (x *elementIndex) build() {
+	x.built = true
+	for key, values := range x.src {
+		for dot := strings.IndexByte(key, '.'); dot != -1; {
+			next := strings.IndexByte(key[dot+1:], '.')
+			if next == -1 {
+				break
+			}
+			next += dot + 1
+			if i, err := utils.ParseInt(key[dot+1 : next]); err == nil && i >= 0 {
+				if x.elems == nil {
+					x.elems = make(map[string]map[int64]map[string][]string)
 				}
-			} else {
-				// this check is to handle if the wrong value is provided
-				conv := getBuiltinConverter(f.typ.Kind())
-				if conv == nil {
-					errs = appendError(errs, "default-"+f.name, errors.New("default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices"))
-				} else if convertedVal := conv(f.defaultValue); convertedVal.IsValid() {
-					// Builtin converters return the underlying kind; convert to
-					// the field's (possibly named) type before assigning.
-					vCurrent.Set(convertedVal.Convert(f.typ))
+				slice := x.elems[key[:dot]]
+				if slice == nil {
+					slice = make(map[int64]map[string][]string)
+					x.elems[key[:dot]] = slice
 				}
+				if slice[i] == nil {
+					slice[i] = make(map[string][]string)
+				}
+				slice[i][key[next+1:]] = values
 			}
+			dot = next
 		}
 	}
+}
 
-	return errs
+// singleValue returns the value of a field holding a single value: the last
+// of values, unless the duplicate policy says otherwise, or "" when there is
+// none.
+func (d *Decoder) singleValue(path string, values []string) (string, error) {
+	switch {
+	case len(values) == 0:
+		return "", nil
+	case len(values) == 1 || d.duplicatePolicy == DuplicateLast:
+		return values[len(values)-1], nil
+	case d.duplicatePolicy == DuplicateFirst:
+		return values[0], nil
+	}
+	return "", DuplicateValueError{Key: path, Count: len(values)}
+}
+
+// isNull reports whether value decodes to a nil pointer.
+func (d *Decoder) isNull(value string) bool {
+	return (d.nullValue != "" && value == d.nullValue) || (d.emptyAsNull && value == "")
+}
+
+// decodeInterface sets v, an interface field, to values, or to the nested
+// maps holding values at anyPath when it is not empty.
+func decodeInterface(v reflect.Value, anyPath string, values []string) {
+	var value any
+	if len(values) == 1 {
+		value = values[0]
+	} else {
+		value = slices.Clone(values)
+	}
+	m, isMap := v.Interface().(map[string]any)
+	if anyPath == "" {
+		// Nested keys win over a value for the field itself.
+		if !isMap {
+			v.Set(reflect.ValueOf(&value).Elem())
+		}
+		return
+	}
+	if !isMap {
+		m = make(map[string]any)
+		v.Set(reflect.ValueOf(m))
+	}
+	for {
+		key, rest, nested := strings.Cut(anyPath, ".")
+		if !nested {
+			if _, isMap := m[key].(map[string]any); !isMap {
+				m[key] = value
+			}
+			return
+		}
+		next, isMap := m[key].(map[string]any)
+		if !isMap {
+			next = make(map[string]any)
+			m[key] = next
+		}
+		m, anyPath = next, rest
+	}
+}
+
+// allEmpty reports whether all of values are empty.
+func allEmpty(values []string) bool {
+	for _, value := range values {
+		if value != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// writesEmpty reports whether decoding empty values writes the field, slice
+// element or map value addressed by parts without ZeroEmpty. Single value
+// fields of builtin kinds and time.Time are left unchanged; other fields
+// are set by their converter, allocated, marked present or null, and slices
+// and map entries are set.
+func (d *Decoder) writesEmpty(parts []pathPart) bool {
+	part := parts[len(parts)-1]
+	f := part.field
+	if part.elem || part.mapValue || f.isInterface || f.isMultipart || f.isOptional || f.isSQLNull ||
+		f.unmarshalerInfo.IsValid {
+		return true
+	}
+	switch f.typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return true
+	}
+	return d.cache.converter(f.typ) != nil
+}
+
+// canonicalPath returns the canonical path of the key parsed into parts.
+func canonicalPath(parts []pathPart) string {
+	var b strings.Builder
+	for i, part := range parts {
+		for _, hop := range part.hops {
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(hop.field.canonicalAlias)
+		}
+		switch {
+		case i == len(parts)-1:
+			if part.anyPath != "" {
+				b.WriteByte('.')
+				b.WriteString(part.anyPath)
+			}
+		case part.field.isMap:
+			b.WriteByte('.')
+			b.WriteString(part.mapKey)
+		default:
+			b.WriteByte('.')
+			b.WriteString(utils.FormatInt(int64(part.index)))
+		}
+	}
+	return b.String()
+}
+
+// maskPath returns the Go path of the field addressed by parts, up to the
+// first slice, array or map along the path.
+func maskPath(parts []pathPart) string {
+	var b strings.Builder
+	for _, hop := range parts[0].hops {
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(hop.field.goPath)
+	}
+	return b.String()
 }
 
 func isPointerToStruct(v reflect.Value) bool {
@@ -287,16 +836,190 @@ func fieldProvided(src map[string][]string, prefix string, f *fieldInfo) bool {
 // only performs the per-request emptiness checks against src.
 //
 // src is the source map for decoding, we use it here to see if those required fields are included in src
-func (d *Decoder) checkRequired(info *structInfo, src map[string][]string) MultiError {
+// The elements of slices are looked up in elems, the grouping of the source
+// of the Decode, under prefix, the path of src in that source.
+func (d *Decoder) checkRequired(info *structInfo, src map[string][]string, elems *elementIndex, prefix string) MultiError {
 	var errs MultiError
 	for key, fields := range info.requiredFields {
-		if isEmptyFields(fields, src) {
+		required, err := isRequiredIn(fields, src, d.duplicatePolicy)
+		if err != nil {
+			errs = appendError(errs, key, err)
+		} else if required && isEmptyFields(fields, src) {
 			errs = appendError(errs, key, EmptyFieldError{Key: key})
 		}
 	}
+	for _, s := range info.requiredSlices {
+		elem := d.cache.get(s.elem)
+		elements, path := s.elements(elems, prefix)
+		for index, elemSrc := range elements {
+			index := utils.FormatInt(index)
+			keyPrefix := s.key + "." + index + "."
+			for key, err := range d.checkRequired(elem, elemSrc, elems, path+"."+index+".") {
+				if empty, ok := err.(EmptyFieldError); ok {
+					empty.Key = keyPrefix + empty.Key
+					err = empty
+				}
+				errs = appendError(errs, keyPrefix+key, err)
+			}
+		}
+	}
 	return errs
 }
 
+// requiredSlice is a slice of structs whose elements have required fields.
+type requiredSlice struct {
+	key   string          // canonical path of the slice, used in errors.
+	field fieldWithPrefix // the slice field, with its search paths.
+	elem  reflect.Type    // the element struct type.
+}
+
+// elements returns the source of every element of the slice present in
+// elems under prefix, by index, with the keys relative to the element, and
+// the path of the slice in the source. The elements of a slice known by
+// several paths are merged.
+func (s *requiredSlice) elements(elems *elementIndex, prefix string) (map[int64]map[string][]string, string) {
+	var found map[int64]map[string][]string
+	var foundPath string
+	merged := false
+	for _, p := range s.field.searchPaths {
+		e := elems.elements(prefix + p)
+		if e == nil {
+			continue
+		}
+		if found == nil {
+			found, foundPath = e, prefix+p
+			continue
+		}
+		if !merged {
+			// Copy before merging: the index is shared.
+			clone := make(map[int64]map[string][]string, len(found))
+			for i, elemSrc := range found {
+				clone[i] = maps.Clone(elemSrc)
+			}
+			found, merged = clone, true
+		}
+		for i, elemSrc := range e {
+			if found[i] == nil {
+				found[i] = make(map[string][]string, len(elemSrc))
+			}
+			maps.Copy(found[i], elemSrc)
+		}
+	}
+	return found, foundPath
+}
+
+// isRequiredIn reports whether the field known by fields is required given
+// src: always with the required option, otherwise when one of the
+// conditional options holds for one of the prefixes it is known under.
+func isRequiredIn(fields []fieldWithPrefix, src map[string][]string, policy DuplicatePolicy) (bool, error) {
+	for _, f := range fields {
+		if f.isRequired {
+			return true, nil
+		}
+		for i := range f.conds {
+			if f.conds[i].err != nil {
+				return false, f.conds[i].err
+			}
+			if f.conds[i].holds(src, policy) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// requiredCond is a conditional required option of a field:
+// "required_if:Field=value", "required_unless:Field=value",
+// "required_with:Field" or "required_without:Field". Options may list
+// several fields separated by "|", each being a condition; the field is
+// required when any condition holds.
+type requiredCond struct {
+	kind  string     // name of the option.
+	name  string     // alias or Go name of the sibling field.
+	value string     // value compared by required_if and required_unless.
+	field *fieldInfo // resolved sibling field.
+	// conv converts the sibling's values to compare them to want, the
+	// converted value; when nil, values are compared as strings.
+	conv Converter
+	want any
+	err  error // the sibling could not be resolved.
+}
+
+// parseRequiredConds parses the conditional required options.
+func parseRequiredConds(options tagOptions) []requiredCond {
+	if !strings.Contains(string(options), "required_") {
+		return nil
+	}
+	var conds []requiredCond
+	for opt := range strings.SplitSeq(string(options), ",") {
+		kind, param, ok := strings.Cut(opt, ":")
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "required_if", "required_unless", "required_with", "required_without":
+		default:
+			continue
+		}
+		for term := range strings.SplitSeq(param, "|") {
+			cond := requiredCond{kind: kind, name: term}
+			if kind == "required_if" || kind == "required_unless" {
+				cond.name, cond.value, _ = strings.Cut(term, "=")
+			}
+			conds = append(conds, cond)
+		}
+	}
+	return conds
+}
+
+// condWithPrefix is a requiredCond with the search paths of its sibling
+// under the prefix of the dependent field.
+type condWithPrefix struct {
+	*requiredCond
+	sibling fieldWithPrefix
+}
+
+// holds reports whether the condition makes its field required given src,
+// with values chosen by policy.
+func (c *condWithPrefix) holds(src map[string][]string, policy DuplicatePolicy) bool {
+	switch c.kind {
+	case "required_with":
+		return !isEmptyField(c.sibling, src)
+	case "required_without":
+		return isEmptyField(c.sibling, src)
+	}
+	value, ok := c.siblingValue(src, policy)
+	equal := ok && c.equal(value)
+	if c.kind == "required_if" {
+		return equal
+	}
+	return !equal
+}
+
+// siblingValue returns the value of the sibling in src, chosen by policy as
+// for decoding.
+func (c *condWithPrefix) siblingValue(src map[string][]string, policy DuplicatePolicy) (string, bool) {
+	for _, path := range c.sibling.searchPaths {
+		if values := src[path]; len(values) > 0 {
+			if policy == DuplicateFirst {
+				return values[0], true
+			}
+			return values[len(values)-1], true
+		}
+	}
+	return "", false
+}
+
+// equal reports whether value, converted like the sibling field, equals the
+// value of the condition.
+func (c *condWithPrefix) equal(value string) bool {
+	if c.conv != nil {
+		v := c.conv(value)
+		return v.IsValid() && v.Interface() == c.want
+	}
+	return value == c.value
+}
+
 type fieldWithPrefix struct {
 	*fieldInfo
 	prefix string
@@ -306,9 +1029,25 @@ type fieldWithPrefix struct {
 	// nothing.
 	searchPaths    []string
 	searchPathDots []string
+	// conds are the resolved conditional required options of the field.
+	conds []condWithPrefix
 }
 
 func newFieldWithPrefix(f *fieldInfo, prefix string) fieldWithPrefix {
+	fp := searchFieldWithPrefix(f, prefix)
+	for i := range f.requiredConds {
+		cond := condWithPrefix{requiredCond: &f.requiredConds[i]}
+		if cond.field != nil {
+			cond.sibling = searchFieldWithPrefix(cond.field, prefix)
+		}
+		fp.conds = append(fp.conds, cond)
+	}
+	return fp
+}
+
+// searchFieldWithPrefix returns f with its search paths under prefix, but
+// without its conditions.
+func searchFieldWithPrefix(f *fieldInfo, prefix string) fieldWithPrefix {
 	paths := f.paths(prefix)
 	dots := make([]string, len(paths))
 	for i, p := range paths {
@@ -325,22 +1064,30 @@ func newFieldWithPrefix(f *fieldInfo, prefix string) fieldWithPrefix {
 // isEmptyFields returns true if all of specified fields are empty.
 func isEmptyFields(fields []fieldWithPrefix, src map[string][]string) bool {
 	for _, f := range fields {
-		for i, path := range f.searchPaths {
-			v, ok := src[path]
-			if ok && !isEmpty(f.typ, v) {
-				return false
-			}
-			// Check for nested keys that match this field.
-			pathDot := f.searchPathDots[i]
-			for key, val := range src {
-				if len(val) == 0 {
-					continue
-				}
-				// for nested structs
-				if strings.HasPrefix(key, pathDot) {
-					if !isEmpty(f.typ, val) {
-						return false
-					}
+		if !isEmptyField(f, src) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEmptyField returns true if f has no value in src, nested keys included.
+func isEmptyField(f fieldWithPrefix, src map[string][]string) bool {
+	for i, path := range f.searchPaths {
+		v, ok := src[path]
+		if ok && !isEmpty(f.valueType(), v) {
+			return false
+		}
+		// Check for nested keys that match this field.
+		pathDot := f.searchPathDots[i]
+		for key, val := range src {
+			if len(val) == 0 {
+				continue
+			}
+			// for nested structs
+			if strings.HasPrefix(key, pathDot) {
+				if !isEmpty(f.valueType(), val) {
+					return false
 				}
 			}
 		}
@@ -488,13 +1235,79 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		return nil
 	}
 
+	if parts[0].field.isInterface {
+		decodeInterface(v, parts[0].anyPath, values)
+		return nil
+	}
+
 	// Check multipart files
 	if parts[0].field.isMultipart && handleMultipartField(v, files) {
 		return nil
 	}
 
+	if parts[0].field.isOptional {
+		return d.decodeOptional(v, path, parts, values, files)
+	}
+	if parts[0].field.isSQLNull {
+		return d.decodeSQLNull(v, path, parts, values, files)
+	}
+	return d.decodeValue(v, path, parts, values, files)
+}
+
+// decodeSQLNull decodes values into v, a database/sql Null field: an empty
+// or null value makes it invalid.
+func (d *Decoder) decodeSQLNull(v reflect.Value, path string, parts []pathPart, values []string, files []*multipart.FileHeader) error {
+	val, err := d.singleValue(path, values)
+	if err != nil {
+		return err
+	}
+	if val == "" || d.isNull(val) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if err := d.decodeValue(v.Field(sqlNullValue), path, parts, values, files); err != nil {
+		return err
+	}
+	v.Field(sqlNullValid).SetBool(true)
+	return nil
+}
+
+// decodeOptional decodes values into v, an Optional field, marking it
+// present, and null for the null value.
+func (d *Decoder) decodeOptional(v reflect.Value, path string, parts []pathPart, values []string, files []*multipart.FileHeader) error {
+	if len(values) > 0 && (d.nullValue != "" || d.emptyAsNull) {
+		val, err := d.singleValue(path, values)
+		if err != nil {
+			return err
+		}
+		if d.isNull(val) {
+			v.Set(reflect.Zero(v.Type()))
+			setOptional(v, true)
+			return nil
+		}
+	}
+	if err := d.decodeValue(v.Field(optionalValue), path, parts, values, files); err != nil {
+		return err
+	}
+	setOptional(v, false)
+	return nil
+}
+
+// decodeValue decodes values into v, the field, slice element or map value
+// addressed by parts.
+func (d *Decoder) decodeValue(v reflect.Value, path string, parts []pathPart, values []string, files []*multipart.FileHeader) error {
 	// Dereference if needed.
 	t := v.Type()
+	if t.Kind() == reflect.Ptr && len(parts) == 1 && len(values) > 0 && (d.nullValue != "" || d.emptyAsNull) {
+		val, err := d.singleValue(path, values)
+		if err != nil {
+			return err
+		}
+		if d.isNull(val) {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+	}
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
 		if v.IsNil() {
@@ -503,9 +1316,26 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		v = v.Elem()
 	}
 
+	// Map entry. Decode into a copy of the entry and store it back, since
+	// map values are not addressable.
+	if len(parts) > 1 && parts[0].field.isMap {
+		return d.decodeMapEntry(v, t, path, parts, values, files)
+	}
+
 	// Slice of structs. Let's go recursive.
 	if len(parts) > 1 {
 		idx := parts[0].index
+		if t.Kind() == reflect.Array {
+			if idx >= v.Len() {
+				return ConversionError{
+					Key:   path,
+					Type:  t,
+					Index: idx,
+					Err:   fmt.Errorf("index out of range for array of length %d", v.Len()),
+				}
+			}
+			return d.decode(v.Index(idx), path, parts[1:], values, files)
+		}
 		// a defensive check to avoid creating a large slice based on user input index
 		if idx > d.maxSize {
 			return fmt.Errorf("%v index %d is larger than the configured maxSize %d", v.Kind(), idx, d.maxSize)
@@ -533,8 +1363,10 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 	m := parts[0].field.derefUnmarshaler
 	if parts[0].elem {
 		m = parts[0].field.elemUnmarshaler
+	} else if parts[0].mapValue {
+		m = parts[0].field.mapUnmarshaler
 	}
-	if conv == nil && t.Kind() == reflect.Slice && m.IsSliceElement {
+	if conv == nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && m.IsSliceElement {
 		elemT := t.Elem()
 		isPtrElem := elemT.Kind() == reflect.Ptr
 		if isPtrElem {
@@ -542,11 +1374,12 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		}
 
 		// Try to get a converter for the element type.
-		customConv := d.cache.converter(elemT)
-		conv := customConv
-		if conv == nil {
-			conv = getBuiltinConverter(elemT.Kind())
-			if conv == nil {
+		conv := d.cache.converter(elemT)
+		var builtin Converter
+		isTime := conv == nil && isTimeType(elemT)
+		if conv == nil && !isTime {
+			builtin = getBuiltinConverter(elemT.Kind())
+			if builtin == nil {
 				// As we are not dealing with slice of structs here, we don't need to check if the type
 				// implements TextUnmarshaler interface
 				return fmt.Errorf("schema: converter not found for %v", elemT)
@@ -556,7 +1389,7 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		// Fast path: builtin element kinds without unmarshalers, custom
 		// converters or pointer elements decode straight into a fresh slice,
 		// avoiding one reflect.Value allocation per element.
-		if customConv == nil && !m.IsValid && !isPtrElem {
+		if conv == nil && !isTime && !m.IsValid && !isPtrElem {
 			return d.decodeBuiltinSlice(v, t, path, values)
 		}
 
@@ -569,10 +1402,23 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		}()
 
 		for key, value := range values {
-			if value == "" {
+			if isPtrElem && d.isNull(value) {
+				items = append(items, reflect.Zero(t.Elem()))
+			} else if value == "" {
 				if d.zeroEmpty {
 					items = append(items, reflect.Zero(t.Elem()))
 				}
+			} else if isTime {
+				item, err := d.convertTime(elemT, parts[0].field.layout, value)
+				if err != nil {
+					return ConversionError{
+						Key:   path,
+						Type:  elemT,
+						Index: key,
+						Err:   err,
+					}
+				}
+				items = appendConvertedItem(items, item, elemT, isPtrElem)
 			} else if m.IsValid {
 				u := reflect.New(elemT)
 				if m.IsSliceElementPtr {
@@ -594,7 +1440,7 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 					// pointed-to value.
 					items = append(items, u.Elem())
 				}
-			} else if item := conv(value); item.IsValid() {
+			} else if item, err := convert(conv, builtin, value); err == nil && item.IsValid() {
 				items = appendConvertedItem(items, item, elemT, isPtrElem)
 			} else {
 				if strings.IndexByte(value, ',') != -1 {
@@ -603,13 +1449,14 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 							if d.zeroEmpty {
 								items = append(items, reflect.Zero(t.Elem()))
 							}
-						} else if item := conv(value); item.IsValid() {
+						} else if item, err := convert(conv, builtin, value); err == nil && item.IsValid() {
 							items = appendConvertedItem(items, item, elemT, isPtrElem)
 						} else {
 							return ConversionError{
 								Key:   path,
 								Type:  elemT,
 								Index: key,
+								Err:   err,
 							}
 						}
 					}
@@ -618,30 +1465,54 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 						Key:   path,
 						Type:  elemT,
 						Index: key,
+						Err:   err,
 					}
 				}
 			}
 		}
-		value := reflect.MakeSlice(t, len(items), len(items))
+		var value reflect.Value
+		if t.Kind() == reflect.Array {
+			if len(items) > t.Len() {
+				return arrayOverflowError(path, t, len(items))
+			}
+			value = reflect.New(t).Elem()
+		} else {
+			value = reflect.MakeSlice(t, len(items), len(items))
+		}
 		for i, item := range items {
 			value.Index(i).Set(item)
 		}
 		v.Set(value)
 	} else {
-		val := ""
-		// Use the last value provided if any values were provided
-		if len(values) > 0 {
-			val = values[len(values)-1]
+		val, err := d.singleValue(path, values)
+		if err != nil {
+			return err
 		}
 
 		if conv != nil {
-			if value := conv(val); value.IsValid() {
+			if value, err := conv(val); err == nil && value.IsValid() {
 				v.Set(value.Convert(t))
 			} else {
 				return ConversionError{
 					Key:   path,
 					Type:  t,
 					Index: -1,
+					Err:   err,
+				}
+			}
+		} else if isTimeType(t) {
+			if val == "" {
+				if d.zeroEmpty {
+					v.Set(reflect.Zero(t))
+				}
+			} else if value, err := d.convertTime(t, parts[0].field.layout, val); err == nil {
+				v.Set(value)
+			} else {
+				return ConversionError{
+					Key:   path,
+					Type:  t,
+					Index: -1,
+					Err:   err,
 				}
 			}
 		} else if m.IsValid {
@@ -690,6 +1561,56 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 	return nil
 }
 
+// decodeMapEntry decodes the rest of the path into the entry of map v (of
+// type t) addressed by parts[0].mapKey, allocating the map when nil. The
+// entry is decoded into an addressable copy and stored back only on success.
+// New entries are capped by maxSize, like slice indices.
+func (d *Decoder) decodeMapEntry(v reflect.Value, t reflect.Type, path string, parts []pathPart, values []string, files []*multipart.FileHeader) error {
+	keyT := t.Key()
+	key, err := d.convertMapKey(keyT, parts[0].mapKey)
+	if err != nil || !key.IsValid() {
+		return ConversionError{
+			Key:   path,
+			Type:  keyT,
+			Index: -1,
+			Err:   err,
+		}
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	entry := reflect.New(t.Elem()).Elem()
+	if existing := v.MapIndex(key); existing.IsValid() {
+		entry.Set(existing)
+	} else if v.Len() >= d.maxSize {
+		// a defensive check to avoid creating a large map based on user input keys
+		return fmt.Errorf("%v size %d reached the configured maxSize %d", v.Kind(), v.Len(), d.maxSize)
+	}
+	if err := d.decode(entry, path, parts[1:], values, files); err != nil {
+		return err
+	}
+	v.SetMapIndex(key, entry)
+	return nil
+}
+
+// convertMapKey converts a raw map key from a path to the map's key type,
+// through a registered converter or the builtin one for its kind. An invalid
+// value is returned when the key does not convert.
+func (d *Decoder) convertMapKey(keyT reflect.Type, raw string) (reflect.Value, error) {
+	conv := d.cache.converter(keyT)
+	var builtin Converter
+	if conv == nil {
+		if builtin = getBuiltinConverter(keyT.Kind()); builtin == nil {
+			return invalidValue, nil
+		}
+	}
+	key, err := convert(conv, builtin, raw)
+	if err != nil || !key.IsValid() {
+		return invalidValue, err
+	}
+	return key.Convert(keyT), nil
+}
+
 // appendConvertedItem converts a builtin/custom converter result to the slice
 // element type and appends it, wrapping it in a freshly allocated pointer for
 // pointer-element slices. The conversion must happen before the pointer wrap:
@@ -708,11 +1629,11 @@ func appendConvertedItem(items []reflect.Value, item reflect.Value, elemT reflec
 	return append(items, item)
 }
 
-// decodeBuiltinSlice decodes values into the slice field v of type t whose
-// elements are builtin-convertible kinds, parsing directly into slice slots
-// instead of boxing every element in a reflect.Value. The slice is built
-// detached and only assigned to v when every value parsed, matching the
-// all-or-nothing behavior of the generic path.
+// decodeBuiltinSlice decodes values into the slice (or array) field v of
+// type t whose elements are builtin-convertible kinds, parsing directly into
+// slice slots instead of boxing every element in a reflect.Value. The slice
+// is built detached and only assigned to v when every value parsed, matching
+// the all-or-nothing behavior of the generic path.
 //
 // A value that fails to parse as a whole is retried as a comma-separated
 // list. For non-string kinds a value containing a comma can never parse as a
@@ -733,7 +1654,11 @@ func (d *Decoder) decodeBuiltinSlice(v reflect.Value, t reflect.Type, path strin
 		n++
 	}
 
-	sl := reflect.MakeSlice(t, n, n)
+	st := t
+	if t.Kind() == reflect.Array {
+		st = reflect.SliceOf(elemT)
+	}
+	sl := reflect.MakeSlice(st, n, n)
 	i := 0
 	for key, value := range values {
 		switch {
@@ -772,10 +1697,32 @@ func (d *Decoder) decodeBuiltinSlice(v reflect.Value, t reflect.Type, path strin
 	if i < n {
 		sl = sl.Slice(0, i)
 	}
+	if t.Kind() == reflect.Array {
+		if i > t.Len() {
+			return arrayOverflowError(path, t, i)
+		}
+		arr := reflect.New(t).Elem()
+		reflect.Copy(arr, sl)
+		v.Set(arr)
+		return nil
+	}
 	v.Set(sl)
 	return nil
 }
 
+// arrayOverflowError reports n values decoded for the array type t, which
+// can't hold them.
+func arrayOverflowError(path string, t reflect.Type, n int) error {
+	return ConversionError{
+		Key:   path,
+		Type:  t,
+		Index: -1,
+		Err:   fmt.Errorf("%d values exceed the array length %d", n, t.Len()),
+	}
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
 func isTextUnmarshaler(v reflect.Value) unmarshaler {
 	// Create a new unmarshaller instance
 	m := unmarshaler{}
@@ -790,12 +1737,12 @@ func isTextUnmarshaler(v reflect.Value) unmarshaler {
 		return m
 	}
 
-	// if v is []T or *[]T create new T
+	// if v is []T, [N]T or *[]T create new T
 	t := v.Type()
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
-	if t.Kind() == reflect.Slice {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
 		// The slice type itself cannot implement encoding.TextUnmarshaler
 		// here: the value-level assert above already covered it. Check
 		// whether the elements do.
@@ -867,6 +1814,54 @@ func (e UnknownKeyError) Error() string {
 	return fmt.Sprintf("schema: invalid path %q", e.Key)
 }
 
+// ValidationError stores information about a value that breaks a validation
+// rule of its field.
+type ValidationError struct {
+	Key   string // key from the source map.
+	Rule  string // name of the rule, like "min" or "email".
+	Param string // parameter of the rule, like "3" for "min:3".
+	Index int    // index of the failing element of a slice; -1 otherwise.
+
+	reason string
+}
+
+func (e ValidationError) Error() string {
+	if e.Index >= 0 {
+		return fmt.Sprintf("schema: element %d of %q %s", e.Index, e.Key, e.reason)
+	}
+	return fmt.Sprintf("schema: %q %s", e.Key, e.reason)
+}
+
+// AliasConflictError stores information about an alias shared by several
+// fields of a struct, directly or promoted from embedded structs. Dominant is
+// the Go path of the field that gets the alias, following the dominance
+// rules of encoding/json, or empty when the alias is ambiguous.
+type AliasConflictError struct {
+	Type     reflect.Type // struct type.
+	Alias    string       // shared alias.
+	Fields   []string     // Go paths of the fields, like "Base.ID".
+	Dominant string       // Go path of the dominant field.
+}
+
+func (e AliasConflictError) Error() string {
+	fields := strings.Join(e.Fields, ", ")
+	if e.Dominant != "" {
+		return fmt.Sprintf("schema: alias %q of %v is shared by fields %s; %s shadows the others", e.Alias, e.Type, fields, e.Dominant)
+	}
+	return fmt.Sprintf("schema: ambiguous alias %q of %v shared by fields %s", e.Alias, e.Type, fields)
+}
+
+// DuplicateValueError stores information about a key with several values for
+// a field that holds a single value, reported with the DuplicateError policy.
+type DuplicateValueError struct {
+	Key   string // key from the source map.
+	Count int    // number of values.
+}
+
+func (e DuplicateValueError) Error() string {
+	return fmt.Sprintf("schema: %d values for single-value field %q", e.Count, e.Key)
+}
+
 // EmptyFieldError stores information about an empty required field.
 type EmptyFieldError struct {
 	Key string // required key in the source map.
@@ -876,16 +1871,44 @@ func (e EmptyFieldError) Error() string {
 	return fmt.Sprintf("%v is empty", e.Key)
 }
 
-// MultiError stores multiple decoding errors.
+// MultiError stores multiple decoding errors, keyed by the path of the
+// field they were reported for.
+//
+// Being a map, ranging over it visits the errors in random order; Errors,
+// All and Unwrap visit them sorted by path, and Error reports the first of
+// them in that order.
 //
 // Borrowed from the App Engine SDK.
 type MultiError map[string]error
 
+// FieldError is a single error of a MultiError with the path it was
+// reported for.
+type FieldError struct {
+	Path string // key of the error in the MultiError.
+	Err  error
+}
+
+func (e FieldError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
 func (e MultiError) Error() string {
-	s := ""
-	for _, err := range e {
-		s = err.Error()
-		break
+	// Report the error of the smallest path, without sorting all of them.
+	var firstErr error
+	first, found := "", false
+	for path, err := range e {
+		if !found || path < first {
+			firstErr, first, found = err, path, true
+		}
+	}
+	var s string
+	if found {
+		s = firstErr.Error()
 	}
 	switch len(e) {
 	case 0:
@@ -898,6 +1921,59 @@ func (e MultiError) Error() string {
 	return fmt.Sprintf("%s (and %d other errors)", s, len(e)-1)
 }
 
+// FullError returns all errors sorted by path, one per line, each prefixed
+// with its path.
+func (e MultiError) FullError() string {
+	if len(e) == 0 {
+		return "(0 errors)"
+	}
+	var b strings.Builder
+	for i, path := range e.Paths() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(path)
+		b.WriteString(": ")
+		b.WriteString(e[path].Error())
+	}
+	return b.String()
+}
+
+// Paths returns the paths errors were reported for, sorted.
+func (e MultiError) Paths() []string {
+	return slices.Sorted(maps.Keys(e))
+}
+
+// Errors returns the errors with their paths, sorted by path.
+func (e MultiError) Errors() []FieldError {
+	errs := make([]FieldError, 0, len(e))
+	for _, path := range e.Paths() {
+		errs = append(errs, FieldError{Path: path, Err: e[path]})
+	}
+	return errs
+}
+
+// All returns an iterator over the paths and errors, sorted by path.
+func (e MultiError) All() iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for _, path := range e.Paths() {
+			if !yield(path, e[path]) {
+				return
+			}
+		}
+	}
+}
+
+// Unwrap returns the errors sorted by path, so errors.Is and errors.As reach
+// the ConversionError, EmptyFieldError or UnknownKeyError of any field.
+func (e MultiError) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, path := range e.Paths() {
+		errs = append(errs, e[path])
+	}
+	return errs
+}
+
 func appendRequiredField(m map[string][]fieldWithPrefix, key string, field fieldWithPrefix) map[string][]fieldWithPrefix {
 	if m == nil {
 		m = make(map[string][]fieldWithPrefix)
@@ -5,11 +5,14 @@
 package schema
 
 import (
+	"database/sql"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"mime/multipart"
+	"net/netip"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -1510,6 +1513,116 @@ type S16 struct {
 	F2 string
 }
 
+func TestRegisterConverterE(t *testing.T) {
+	type level int
+	errBadLevel := errors.New("level must be low or high")
+	decoder := NewDecoder()
+	decoder.RegisterConverterE(level(0), func(s string) (reflect.Value, error) {
+		switch s {
+		case "low":
+			return reflect.ValueOf(level(1)), nil
+		case "high":
+			return reflect.ValueOf(level(2)), nil
+		}
+		return invalidValue, errBadLevel
+	})
+
+	type S struct {
+		Level  level         `schema:"level"`
+		Levels []level       `schema:"levels"`
+		ByLvl  map[level]int `schema:"by"`
+	}
+	var s S
+	err := decoder.Decode(&s, map[string][]string{
+		"level":   {"high"},
+		"levels":  {"low", "high"},
+		"by.high": {"3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Level != 2 || len(s.Levels) != 2 || s.Levels[0] != 1 || s.Levels[1] != 2 || s.ByLvl[2] != 3 {
+		t.Fatalf("unexpected result %+v", s)
+	}
+
+	for _, key := range []string{"level", "levels", "by.mid"} {
+		src := map[string][]string{key: {"mid"}}
+		if key == "by.mid" {
+			src[key] = []string{"1"}
+		}
+		err := decoder.Decode(&S{}, src)
+		var conv ConversionError
+		if !errors.As(err, &conv) {
+			t.Fatalf("%s: expected a ConversionError, got %v", key, err)
+		}
+		if !errors.Is(conv.Err, errBadLevel) {
+			t.Errorf("%s: expected the converter error, got %v", key, conv.Err)
+		}
+	}
+}
+
+func TestRegisterConverterFunc(t *testing.T) {
+	type point struct{ X, Y int }
+	decoder := NewDecoder()
+	RegisterConverterFunc(decoder, func(s string) (point, error) {
+		xs, ys, ok := strings.Cut(s, ",")
+		if !ok {
+			return point{}, fmt.Errorf("%q is not an x,y pair", s)
+		}
+		x, err := strconv.Atoi(xs)
+		if err != nil {
+			return point{}, err
+		}
+		y, err := strconv.Atoi(ys)
+		if err != nil {
+			return point{}, err
+		}
+		return point{x, y}, nil
+	})
+
+	type S struct {
+		At  point   `schema:"at"`
+		Ptr *point  `schema:"ptr"`
+		All []point `schema:"all"`
+	}
+	// Slices of structs keep their indexed paths when the element has a
+	// converter.
+	var s S
+	err := decoder.Decode(&s, map[string][]string{
+		"at":    {"1,2"},
+		"ptr":   {"3,4"},
+		"all.0": {"5,6"},
+		"all.1": {"7,8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.At != (point{1, 2}) || s.Ptr == nil || *s.Ptr != (point{3, 4}) || len(s.All) != 2 || s.All[1] != (point{7, 8}) {
+		t.Fatalf("unexpected result %+v", s)
+	}
+
+	err = decoder.Decode(&S{}, map[string][]string{"at": {"1"}})
+	var conv ConversionError
+	if !errors.As(err, &conv) || conv.Key != "at" || conv.Err == nil || conv.Err.Error() != `"1" is not an x,y pair` {
+		t.Fatalf("expected the converter error in ConversionError.Err, got %v", err)
+	}
+}
+
+func TestRegisterConverterNilErr(t *testing.T) {
+	// Legacy converters still report failures with an invalid value and no
+	// error.
+	type custom struct{ s string }
+	decoder := NewDecoder()
+	decoder.RegisterConverter(custom{}, func(string) reflect.Value { return invalidValue })
+	err := decoder.Decode(&struct {
+		T custom `schema:"t"`
+	}{}, map[string][]string{"t": {"x"}})
+	var conv ConversionError
+	if !errors.As(err, &conv) || conv.Err != nil {
+		t.Fatalf("expected a ConversionError without Err, got %v", err)
+	}
+}
+
 func TestCustomTypeSlice(t *testing.T) {
 	data := map[string][]string{
 		"Value.0": {"Louisa May Alcott"},
@@ -1787,6 +1900,213 @@ func TestRequiredFieldIsMissingCorrectError(t *testing.T) {
 	}
 }
 
+func TestRequiredConditional(t *testing.T) {
+	type Shipping struct {
+		Method  string `schema:"method"`
+		Address string `schema:"address,required_if:method=post"`
+		Pickup  string `schema:"pickup,required_unless:method=post"`
+	}
+	type Contact struct {
+		Email    string   `schema:"email,required_without:phone"`
+		Phone    string   `schema:"phone"`
+		Country  string   `schema:"country,required_with:zip|city"`
+		Zip      string   `schema:"zip"`
+		City     string   `schema:"city"`
+		Company  bool     `schema:"company"`
+		VAT      string   `schema:"vat,required_if:Company=true"`
+		Shipping Shipping `schema:"shipping"`
+	}
+
+	tests := []struct {
+		name    string
+		src     map[string][]string
+		missing []string
+	}{
+		{
+			name:    "none",
+			src:     map[string][]string{},
+			missing: []string{"email", "shipping.pickup"},
+		},
+		{
+			name: "satisfied",
+			src: map[string][]string{
+				"phone":            {"555"},
+				"shipping.method":  {"post"},
+				"shipping.address": {"1 Main St"},
+			},
+		},
+		{
+			name: "with",
+			src: map[string][]string{
+				"email":           {"a@example.com"},
+				"city":            {"Paris"},
+				"shipping.pickup": {"store"},
+			},
+			missing: []string{"country"},
+		},
+		{
+			name: "if",
+			src: map[string][]string{
+				"email":           {"a@example.com"},
+				"company":         {"on"},
+				"shipping.method": {"post"},
+			},
+			missing: []string{"shipping.address", "vat"},
+		},
+		{
+			name: "last value",
+			src: map[string][]string{
+				"email":           {"a@example.com"},
+				"company":         {"true", "false"},
+				"shipping.method": {"post", "pickup"},
+				"shipping.pickup": {"store"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Contact
+			err := NewDecoder().Decode(&c, tt.src)
+			if len(tt.missing) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var errs MultiError
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected MultiError, got %v", err)
+			}
+			if got := errs.Paths(); !slices.Equal(got, tt.missing) {
+				t.Fatalf("expected errors for %v, got %v", tt.missing, errs)
+			}
+			for _, key := range tt.missing {
+				var empty EmptyFieldError
+				if !errors.As(errs[key], &empty) || empty.Key != key {
+					t.Errorf("expected EmptyFieldError for %q, got %v", key, errs[key])
+				}
+			}
+		})
+	}
+}
+
+func TestRequiredSliceOfStructs(t *testing.T) {
+	type Item struct {
+		SKU  string `schema:"SKU,required"`
+		Qty  int    `schema:"Qty,default:1"`
+		Note string `schema:"Note,required_with:Qty"`
+	}
+	type Order struct {
+		Items []Item  `schema:"Items"`
+		Extra []*Item `schema:"Extra"`
+	}
+	type Form struct {
+		Order Order `schema:"Order"`
+	}
+
+	var f Form
+	err := NewDecoder().Decode(&f, map[string][]string{
+		"Order.Items.0.SKU":  {"a"},
+		"Order.Items.2.Note": {"n"},
+		"Order.Extra.1.SKU":  {"b"},
+		"Order.Extra.1.Qty":  {"3"},
+	})
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+	want := []string{"Order.Extra.1.Note", "Order.Items.2.SKU"}
+	if got := errs.Paths(); !slices.Equal(got, want) {
+		t.Fatalf("expected errors for %v, got %v", want, errs.FullError())
+	}
+	if got := errs["Order.Items.2.SKU"].Error(); got != "Order.Items.2.SKU is empty" {
+		t.Errorf("unexpected error message %q", got)
+	}
+
+	items := f.Order.Items
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(items))
+	}
+	// Only the elements present in the source get defaults.
+	if items[0].Qty != 1 || items[1].Qty != 0 || items[2].Qty != 1 {
+		t.Errorf("unexpected default quantities: %+v", items)
+	}
+	if f.Order.Extra[0] != nil || f.Order.Extra[1].Qty != 3 {
+		t.Errorf("unexpected extra items: %+v", f.Order.Extra)
+	}
+}
+
+func TestRequiredSliceOfStructsSatisfied(t *testing.T) {
+	type Item struct {
+		SKU string `schema:"sku,required"`
+	}
+	type Order struct {
+		Items []Item `schema:"items"`
+	}
+
+	var o Order
+	err := NewDecoder().Decode(&o, map[string][]string{
+		"items.0.sku": {"a"},
+		"items.1.sku": {"b"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := NewDecoder().Decode(&o, map[string][]string{}); err != nil {
+		t.Fatalf("unexpected error without elements: %v", err)
+	}
+}
+
+func TestRequiredNestedSliceOfStructs(t *testing.T) {
+	type Tag struct {
+		Name  string `schema:"name,required"`
+		Color string `schema:"color,default:red"`
+	}
+	type Item struct {
+		SKU  string `schema:"sku,required"`
+		Tags []Tag  `schema:"tags"`
+	}
+	type Order struct {
+		Items []Item `schema:"items"`
+	}
+
+	var o Order
+	err := NewDecoder().Decode(&o, map[string][]string{
+		"items.0.sku":          {"a"},
+		"items.0.tags.0.name":  {"x"},
+		"items.0.tags.1.color": {"blue"},
+		"items.1.tags.0.name":  {"y"},
+	})
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+	want := []string{"items.0.tags.1.name", "items.1.sku"}
+	if got := errs.Paths(); !slices.Equal(got, want) {
+		t.Fatalf("expected errors for %v, got %v", want, errs.FullError())
+	}
+	if got := errs["items.0.tags.1.name"].Error(); got != "items.0.tags.1.name is empty" {
+		t.Errorf("unexpected error message %q", got)
+	}
+	if got := o.Items[0].Tags; got[0].Color != "red" || got[1].Color != "blue" || o.Items[1].Tags[0].Color != "red" {
+		t.Errorf("unexpected tags: %+v", o.Items)
+	}
+}
+
+func TestRequiredConditionalUnknownField(t *testing.T) {
+	type S struct {
+		A string `schema:"a,required_with:missing"`
+	}
+	err := NewDecoder().Decode(&S{}, map[string][]string{"a": {"x"}})
+	var errs MultiError
+	if !errors.As(err, &errs) || errs["a"] == nil {
+		t.Fatalf("expected error for a, got %v", err)
+	}
+	if !strings.Contains(errs["a"].Error(), `unknown field "missing"`) {
+		t.Errorf("unexpected error: %v", errs["a"])
+	}
+}
+
 type AS1 struct {
 	A int32 `schema:"a,required"`
 	E int32 `schema:"e,required"`
@@ -2672,12 +2992,17 @@ func TestRequiredFieldsCannotHaveDefaults(t *testing.T) {
 
 	decoder := NewDecoder()
 
-	err := decoder.Decode(&d, data)
+	err := decoder.Prepare(d)
 
 	expected := "required fields cannot have a default value"
 
 	if err == nil || !strings.Contains(err.Error(), expected) {
-		t.Errorf("decoding should fail with error msg %s got %q", expected, err)
+		t.Errorf("prepare should fail with error msg %s got %q", expected, err)
+	}
+
+	// Decode ignores the defaults.
+	if err := decoder.Decode(&d, data); err != nil {
+		t.Errorf("decoding should succeed but got error: %q", err)
 	}
 }
 
@@ -2686,7 +3011,7 @@ func TestNestedDefaultErrorsDoNotPanic(t *testing.T) {
 		t.Helper()
 
 		if err == nil {
-			t.Fatal("expected decode error")
+			t.Fatal("expected prepare error")
 		}
 
 		multiErr, ok := err.(MultiError)
@@ -2718,8 +3043,9 @@ func TestNestedDefaultErrorsDoNotPanic(t *testing.T) {
 		}
 
 		var dst Outer
-		err := NewDecoder().Decode(&dst, map[string][]string{})
-		assertNestedDefaultErrors(t, err)
+		decoder := NewDecoder()
+		_ = decoder.Decode(&dst, map[string][]string{})
+		assertNestedDefaultErrors(t, decoder.Prepare(dst))
 	})
 
 	t.Run("pointer field", func(t *testing.T) {
@@ -2731,8 +3057,9 @@ func TestNestedDefaultErrorsDoNotPanic(t *testing.T) {
 		}
 
 		dst := Outer{Inner: &Inner{}}
-		err := NewDecoder().Decode(&dst, map[string][]string{})
-		assertNestedDefaultErrors(t, err)
+		decoder := NewDecoder()
+		_ = decoder.Decode(&dst, map[string][]string{})
+		assertNestedDefaultErrors(t, decoder.Prepare(dst))
 	})
 }
 
@@ -2742,19 +3069,21 @@ func TestUnsupportedDefaultTypeDoesNotPanic(t *testing.T) {
 	}
 
 	var dst D
-	err := NewDecoder().Decode(&dst, map[string][]string{})
-	if err == nil {
-		t.Fatal("expected decode error")
+	decoder := NewDecoder()
+	if err := decoder.Decode(&dst, map[string][]string{}); err != nil {
+		t.Fatalf("decoding should succeed but got error: %q", err)
 	}
 
+	err := decoder.Prepare(dst)
 	multiErr, ok := err.(MultiError)
 	if !ok {
 		t.Fatalf("expected MultiError, got %T: %v", err, err)
 	}
 
-	itemErr := multiErr["default-Value"]
+	key := reflect.TypeOf(dst).String() + ".Value"
+	itemErr := multiErr[key]
 	if itemErr == nil {
-		t.Fatalf("expected default-Value error, got %#v", multiErr)
+		t.Fatalf("expected %s error, got %#v", key, multiErr)
 	}
 
 	const want = "default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices"
@@ -2789,24 +3118,33 @@ func TestInvalidDefaultElementInSliceRaiseError(t *testing.T) {
 
 	decoder := NewDecoder()
 
-	err := decoder.Decode(&d, data)
+	if err := decoder.Decode(&d, data); err != nil {
+		t.Errorf("decoding should succeed but got error: %q", err)
+	}
+
+	err := decoder.Prepare(d)
 
 	if err == nil {
-		t.Error("if a different type exists, error should be raised")
+		t.Fatal("if a different type exists, error should be raised")
 	}
 
 	dType := reflect.TypeOf(d)
 
+	// X and Y also share the alias c.
 	e, ok := err.(MultiError)
-	if !ok || len(e) != dType.NumField() {
-		t.Errorf("Expected %d errors, got %#v", dType.NumField(), err)
+	if !ok || len(e) != dType.NumField()+1 {
+		t.Errorf("Expected %d errors, got %#v", dType.NumField()+1, err)
 	}
 
 	for i := 0; i < dType.NumField(); i++ {
 		v := dType.Field(i)
-		fieldKey := "default-" + string(v.Name)
+		fieldKey := dType.String() + "." + v.Name
 		errMsg := fmt.Sprintf("failed setting default: notInt is not compatible with field %s type", string(v.Name))
 		ferr := e[fieldKey]
+		if ferr == nil {
+			t.Errorf("%s: expected an error", fieldKey)
+			continue
+		}
 		if strings.Compare(ferr.Error(), errMsg) != 0 {
 			t.Errorf("%s: expected %s, got %#v\n", fieldKey, ferr.Error(), errMsg)
 		}
@@ -2883,12 +3221,16 @@ func TestDefaultsAreNotSupportedForStructsAndStructSlices(t *testing.T) {
 
 	decoder := NewDecoder()
 
-	err := decoder.Decode(&d, data)
+	if err := decoder.Decode(&d, data); err != nil {
+		t.Errorf("decoding should succeed but got error: %q", err)
+	}
+
+	err := decoder.Prepare(d)
 
 	expected := "default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices"
 
 	if err == nil || !strings.Contains(err.Error(), expected) {
-		t.Errorf("decoding should fail with error msg %s got %q", expected, err)
+		t.Errorf("prepare should fail with error msg %s got %q", expected, err)
 	}
 }
 
@@ -3731,7 +4073,7 @@ func BenchmarkCheckRequiredFields(b *testing.B) {
 	info := decoder.cache.get(v.Type())
 
 	for b.Loop() {
-		_ = decoder.checkRequired(info, data)
+		_ = decoder.checkRequired(info, data, &elementIndex{src: data}, "")
 	}
 }
 
@@ -4696,3 +5038,1312 @@ func TestDecodeDoesNotMutateCallerSrc(t *testing.T) {
 		t.Errorf("caller src mutated with file key: %v", src2)
 	}
 }
+
+// Map fields are filled entry by entry from "alias.key" paths, with keys
+// converted to the map's key type.
+func TestDecodeMapFields(t *testing.T) {
+	type Size struct {
+		W int `schema:"w"`
+		H int `schema:"h"`
+	}
+	type Level int
+	type S struct {
+		Attrs  map[string]string `schema:"attrs"`
+		Tags   map[string][]int  `schema:"tags"`
+		Sizes  map[string]Size   `schema:"sizes"`
+		Ptrs   map[string]*Size  `schema:"ptrs"`
+		ByID   map[int]bool      `schema:"byid"`
+		Levels map[Level]*string `schema:"levels"`
+		PMap   *map[string]int   `schema:"pmap"`
+	}
+
+	src := map[string][]string{
+		"attrs.color":  {"red"},
+		"attrs.a.b":    {"dotted"},
+		"tags.x":       {"1", "2,3"},
+		"sizes.big.w":  {"10"},
+		"sizes.big.h":  {"20"},
+		"ptrs.small.w": {"1"},
+		"byid.7":       {"on"},
+		"levels.2":     {"two"},
+		"pmap.k":       {"5"},
+	}
+	var s S
+	if err := NewDecoder().Decode(&s, src); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(s.Attrs, map[string]string{"color": "red", "a.b": "dotted"}) {
+		t.Errorf("attrs: got %v", s.Attrs)
+	}
+	if !reflect.DeepEqual(s.Tags, map[string][]int{"x": {1, 2, 3}}) {
+		t.Errorf("tags: got %v", s.Tags)
+	}
+	if !reflect.DeepEqual(s.Sizes, map[string]Size{"big": {W: 10, H: 20}}) {
+		t.Errorf("sizes: got %v", s.Sizes)
+	}
+	if p := s.Ptrs["small"]; p == nil || p.W != 1 {
+		t.Errorf("ptrs: got %v", s.Ptrs)
+	}
+	if !reflect.DeepEqual(s.ByID, map[int]bool{7: true}) {
+		t.Errorf("byid: got %v", s.ByID)
+	}
+	if p := s.Levels[2]; p == nil || *p != "two" {
+		t.Errorf("levels: got %v", s.Levels)
+	}
+	if s.PMap == nil || (*s.PMap)["k"] != 5 {
+		t.Errorf("pmap: got %v", s.PMap)
+	}
+
+	// Entries decoded into existing maps keep the other keys.
+	s.Attrs = map[string]string{"keep": "me"}
+	if err := NewDecoder().Decode(&s, map[string][]string{"attrs.color": {"blue"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Attrs, map[string]string{"keep": "me", "color": "blue"}) {
+		t.Errorf("existing map: got %v", s.Attrs)
+	}
+}
+
+func TestDecodeMapKeyConversion(t *testing.T) {
+	type Code string
+	type S struct {
+		ByID   map[int]string  `schema:"byid"`
+		ByCode map[Code]string `schema:"bycode"`
+	}
+
+	d := NewDecoder()
+	d.RegisterConverter(Code(""), func(s string) reflect.Value {
+		return reflect.ValueOf(Code(strings.ToUpper(s)))
+	})
+	var s S
+	err := d.Decode(&s, map[string][]string{
+		"byid.x":    {"bad key"},
+		"bycode.ab": {"ok"},
+	})
+	me, ok := err.(MultiError)
+	if !ok || len(me) != 1 {
+		t.Fatalf("expected one error, got %v", err)
+	}
+	if conv, ok := me["byid.x"].(ConversionError); !ok || conv.Type != reflect.TypeOf(0) {
+		t.Fatalf("expected key ConversionError, got %#v", me["byid.x"])
+	}
+	if s.ByCode["AB"] != "ok" {
+		t.Errorf("registered key converter not used: %v", s.ByCode)
+	}
+}
+
+// MaxSize caps how many entries a request can add to a map, and map paths
+// stay out of the path cache since their keys are client-chosen.
+func TestDecodeMapMaxSizeAndCache(t *testing.T) {
+	type S struct {
+		Attrs map[string]string `schema:"attrs"`
+	}
+
+	d := NewDecoder()
+	d.MaxSize(2)
+	var s S
+	err := d.Decode(&s, map[string][]string{
+		"attrs.a": {"1"},
+		"attrs.b": {"2"},
+		"attrs.c": {"3"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "maxSize") {
+		t.Fatalf("expected maxSize error, got %v", err)
+	}
+	if len(s.Attrs) != 2 {
+		t.Errorf("expected 2 entries, got %v", s.Attrs)
+	}
+
+	count := 0
+	d.cache.get(reflect.TypeOf(s)).paths.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	if count != 0 {
+		t.Errorf("map paths must not be cached, found %d entries", count)
+	}
+}
+
+func TestDecodeMapInvalidPaths(t *testing.T) {
+	type S struct {
+		Attrs map[string]string `schema:"attrs"`
+		Sizes map[string]struct {
+			W int `schema:"w"`
+		} `schema:"sizes"`
+		Nested map[string]map[string]string `schema:"nested"`
+	}
+	for _, key := range []string{"attrs", "attrs.", "sizes.big.nope", "nested.a.b"} {
+		var s S
+		err := NewDecoder().Decode(&s, map[string][]string{key: {"x"}})
+		if me, ok := err.(MultiError); !ok {
+			t.Errorf("%q: expected MultiError, got %v", key, err)
+		} else if _, ok := me[key].(UnknownKeyError); !ok {
+			t.Errorf("%q: expected UnknownKeyError, got %v", key, me[key])
+		}
+	}
+}
+
+func TestBracketToDotted(t *testing.T) {
+	cases := []struct {
+		key, want string
+	}{
+		{"name", "name"},
+		{"phones[0][label]", "phones.0.label"},
+		{"tags[]", "tags"},
+		{"attrs[color]", "attrs.color"},
+		{"attrs[a.b]", "attrs.a.b"},
+		{"phones[0].label", "phones.0.label"},
+		{"a.b[1][c].d", "a.b.1.c.d"},
+		{"tags[x][]", "tags.x"},
+		// malformed keys are left alone
+		{"[0]", "[0]"},
+		{"a[0", "a[0"},
+		{"a[][b]", "a[][b]"},
+		{"a[0]b", "a[0]b"},
+	}
+	for _, c := range cases {
+		if got := bracketToDotted(c.key); got != c.want {
+			t.Errorf("bracketToDotted(%q) = %q, want %q", c.key, got, c.want)
+		}
+	}
+}
+
+func TestDecodeBracketNotation(t *testing.T) {
+	type Phone struct {
+		Label  string `schema:"label,required"`
+		Number string `schema:"number"`
+	}
+	type S struct {
+		Name   string            `schema:"name"`
+		Tags   []string          `schema:"tags"`
+		Phones []Phone           `schema:"phones"`
+		Attrs  map[string]string `schema:"attrs"`
+	}
+
+	src := map[string][]string{
+		"name":              {"jane"},
+		"tags[]":            {"a", "b"},
+		"phones[0][label]":  {"home"},
+		"phones[0][number]": {"1"},
+		"phones[1].label":   {"work"},
+		"attrs[color]":      {"red"},
+	}
+
+	// Without the option, bracket keys are unknown.
+	var s S
+	if err := NewDecoder().Decode(&s, src); err == nil {
+		t.Fatal("expected unknown key errors without bracket notation")
+	}
+
+	d := NewDecoder()
+	d.BracketNotation(true)
+	s = S{}
+	if err := d.Decode(&s, src); err != nil {
+		t.Fatal(err)
+	}
+	expected := S{
+		Name:   "jane",
+		Tags:   []string{"a", "b"},
+		Phones: []Phone{{Label: "home", Number: "1"}, {Label: "work"}},
+		Attrs:  map[string]string{"color": "red"},
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+	if len(src) != 6 {
+		t.Errorf("caller src mutated: %v", src)
+	}
+
+	// Translated keys share the dotted parsed-path cache entries.
+	if _, ok := d.cache.get(reflect.TypeOf(s)).paths.Load("phones.0.label"); !ok {
+		t.Error("expected the dotted path to be cached")
+	}
+
+	// Required checks see the translated keys, and errors use dotted paths.
+	type R struct {
+		Phone Phone `schema:"phone"`
+	}
+	var r R
+	err := d.Decode(&r, map[string][]string{"phone[number]": {"1"}})
+	if me, ok := err.(MultiError); !ok {
+		t.Fatalf("expected MultiError, got %v", err)
+	} else if _, ok := me["phone.label"].(EmptyFieldError); !ok {
+		t.Errorf("expected phone.label to be required, got %v", me)
+	}
+}
+
+func TestDecodeArrays(t *testing.T) {
+	type Point struct {
+		X int `schema:"x"`
+		Y int `schema:"y"`
+	}
+	type S struct {
+		Ints   [3]int      `schema:"ints"`
+		Ptrs   [2]*int     `schema:"ptrs"`
+		Names  [2]rudeBool `schema:"names"`
+		Points [2]Point    `schema:"points"`
+		PArr   *[2]string  `schema:"parr"`
+		Idx    [3]string   `schema:"idx"`
+	}
+
+	var s S
+	err := NewDecoder().Decode(&s, map[string][]string{
+		"ints":       {"1", "2"},
+		"ptrs":       {"3,4"},
+		"names":      {"yup", "nope"},
+		"points.0.x": {"5"},
+		"points.1.y": {"6"},
+		"parr":       {"a"},
+		"idx.2":      {"c"},
+		"idx.0":      {"a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Ints != [3]int{1, 2, 0} {
+		t.Errorf("ints: got %v", s.Ints)
+	}
+	if s.Ptrs[0] == nil || *s.Ptrs[0] != 3 || s.Ptrs[1] == nil || *s.Ptrs[1] != 4 {
+		t.Errorf("ptrs: got %v", s.Ptrs)
+	}
+	if s.Names != [2]rudeBool{true, false} {
+		t.Errorf("names: got %v", s.Names)
+	}
+	if s.Points != [2]Point{{X: 5}, {Y: 6}} {
+		t.Errorf("points: got %v", s.Points)
+	}
+	if s.PArr == nil || *s.PArr != [2]string{"a", ""} {
+		t.Errorf("parr: got %v", s.PArr)
+	}
+	if s.Idx != [3]string{"a", "", "c"} {
+		t.Errorf("idx: got %v", s.Idx)
+	}
+}
+
+func TestDecodeArrayOverflow(t *testing.T) {
+	type Point struct {
+		X int `schema:"x"`
+	}
+	type S struct {
+		Ints   [2]int      `schema:"ints"`
+		Ptrs   [1]*int     `schema:"ptrs"`
+		Points [2]Point    `schema:"points"`
+		Strs   [2]string   `schema:"strs"`
+		Names  [1]rudeBool `schema:"names"`
+	}
+
+	s := S{Ints: [2]int{7, 8}}
+	err := NewDecoder().Decode(&s, map[string][]string{
+		"ints":       {"1", "2,3"},
+		"ptrs":       {"1", "2"},
+		"points.2.x": {"1"},
+		"strs.5":     {"x"},
+		"names":      {"yup", "nope"},
+	})
+	me, ok := err.(MultiError)
+	if !ok || len(me) != 5 {
+		t.Fatalf("expected 5 errors, got %v", err)
+	}
+	for key, err := range me {
+		if _, ok := err.(ConversionError); !ok {
+			t.Errorf("%s: expected ConversionError, got %#v", key, err)
+		}
+	}
+	if s.Ints != [2]int{7, 8} {
+		t.Errorf("overflowing values must leave the array untouched, got %v", s.Ints)
+	}
+}
+
+func TestDecodeAs(t *testing.T) {
+	type S struct {
+		Name string `schema:"name"`
+		Age  int    `schema:"age"`
+	}
+	d := NewDecoder()
+	src := map[string][]string{"name": {"jane"}, "age": {"30"}}
+
+	s, err := DecodeAs[S](d, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != (S{Name: "jane", Age: 30}) {
+		t.Errorf("unexpected result %+v", s)
+	}
+
+	p, err := DecodeAs[*S](d, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil || *p != (S{Name: "jane", Age: 30}) {
+		t.Errorf("unexpected result %+v", p)
+	}
+
+	// The successfully decoded fields are returned with the error.
+	s, err = DecodeAs[S](d, map[string][]string{"name": {"joe"}, "age": {"x"}})
+	var conv ConversionError
+	if !errors.As(err, &conv) || conv.Key != "age" {
+		t.Fatalf("expected a ConversionError for age, got %v", err)
+	}
+	if s.Name != "joe" {
+		t.Errorf("expected the decoded name, got %+v", s)
+	}
+
+	if _, err := DecodeAs[int](d, src); err != errNotPointerToStruct {
+		t.Errorf("expected errNotPointerToStruct, got %v", err)
+	}
+	if _, err := DecodeAs[**S](d, src); err != errNotPointerToStruct {
+		t.Errorf("expected errNotPointerToStruct for **S, got %v", err)
+	}
+}
+
+func TestDecodeAsFiles(t *testing.T) {
+	type S struct {
+		File *multipart.FileHeader `schema:"file"`
+	}
+	fh := &multipart.FileHeader{Filename: "a.txt"}
+	s, err := DecodeAs[S](NewDecoder(), nil, map[string][]*multipart.FileHeader{"file": {fh}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.File != fh {
+		t.Errorf("expected the file header, got %+v", s.File)
+	}
+}
+
+func TestDecodeTime(t *testing.T) {
+	type S struct {
+		RFC      time.Time       `schema:"rfc"`
+		Local    time.Time       `schema:"local"`
+		Seconds  time.Time       `schema:"seconds"`
+		Date     time.Time       `schema:"date"`
+		Clock    time.Time       `schema:"clock"`
+		Ptr      *time.Time      `schema:"ptr"`
+		Layout   time.Time       `schema:"layout,layout:02/01/2006"`
+		Dates    []time.Time     `schema:"dates,layout:2006-01-02"`
+		Timeout  time.Duration   `schema:"timeout"`
+		Nanos    time.Duration   `schema:"nanos"`
+		Timeouts []time.Duration `schema:"timeouts"`
+		Empty    time.Time       `schema:"empty"`
+	}
+	var s S
+	err := NewDecoder().Decode(&s, map[string][]string{
+		"rfc":      {"2024-03-01T10:20:30.5+02:00"},
+		"local":    {"2024-03-01T10:20"},
+		"seconds":  {"2024-03-01T10:20:30"},
+		"date":     {"2024-03-01"},
+		"clock":    {"10:20"},
+		"ptr":      {"2024-03-01"},
+		"layout":   {"01/03/2024"},
+		"dates":    {"2024-03-01", "2024-03-02"},
+		"timeout":  {"1m30s"},
+		"nanos":    {"1500"},
+		"timeouts": {"1s", "2"},
+		"empty":    {""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if want := time.Date(2024, 3, 1, 10, 20, 30, 5e8, time.FixedZone("", 2*3600)); !s.RFC.Equal(want) {
+		t.Errorf("rfc: expected %v, got %v", want, s.RFC)
+	}
+	if want := date.Add(10*time.Hour + 20*time.Minute); !s.Local.Equal(want) {
+		t.Errorf("local: expected %v, got %v", want, s.Local)
+	}
+	if want := date.Add(10*time.Hour + 20*time.Minute + 30*time.Second); !s.Seconds.Equal(want) {
+		t.Errorf("seconds: expected %v, got %v", want, s.Seconds)
+	}
+	if !s.Date.Equal(date) {
+		t.Errorf("date: expected %v, got %v", date, s.Date)
+	}
+	if s.Clock.Hour() != 10 || s.Clock.Minute() != 20 {
+		t.Errorf("clock: expected 10:20, got %v", s.Clock)
+	}
+	if s.Ptr == nil || !s.Ptr.Equal(date) {
+		t.Errorf("ptr: expected %v, got %v", date, s.Ptr)
+	}
+	if !s.Layout.Equal(date) {
+		t.Errorf("layout: expected %v, got %v", date, s.Layout)
+	}
+	if len(s.Dates) != 2 || !s.Dates[1].Equal(date.AddDate(0, 0, 1)) {
+		t.Errorf("dates: unexpected %v", s.Dates)
+	}
+	if s.Timeout != 90*time.Second || s.Nanos != 1500 {
+		t.Errorf("durations: unexpected %v, %v", s.Timeout, s.Nanos)
+	}
+	if len(s.Timeouts) != 2 || s.Timeouts[0] != time.Second || s.Timeouts[1] != 2 {
+		t.Errorf("timeouts: unexpected %v", s.Timeouts)
+	}
+	if !s.Empty.IsZero() {
+		t.Errorf("empty: expected the zero time, got %v", s.Empty)
+	}
+}
+
+func TestDecodeTimeErrors(t *testing.T) {
+	type S struct {
+		Date    time.Time     `schema:"date,layout:2006-01-02"`
+		Dates   []time.Time   `schema:"dates"`
+		Timeout time.Duration `schema:"timeout"`
+	}
+	err := NewDecoder().Decode(&S{}, map[string][]string{
+		"date":    {"2024-03-01T10:20"},
+		"dates":   {"2024-03-01", "tomorrow"},
+		"timeout": {"soon"},
+	})
+	multi, ok := err.(MultiError)
+	if !ok || len(multi) != 3 {
+		t.Fatalf("expected 3 errors, got %v", err)
+	}
+	for path, err := range multi {
+		conv, ok := err.(ConversionError)
+		if !ok || conv.Err == nil {
+			t.Errorf("%s: expected a ConversionError with the parse error, got %v", path, err)
+		}
+	}
+	if idx := multi["dates"].(ConversionError).Index; idx != 1 {
+		t.Errorf("dates: expected index 1, got %d", idx)
+	}
+}
+
+func TestDecoderSetTimeLayout(t *testing.T) {
+	type S struct {
+		Date   time.Time `schema:"date"`
+		Tagged time.Time `schema:"tagged,layout:2006-01-02"`
+	}
+	d := NewDecoder()
+	d.SetTimeLayout("02.01.2006")
+	var s S
+	if err := d.Decode(&s, map[string][]string{"date": {"01.03.2024"}, "tagged": {"2024-03-01"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if !s.Date.Equal(want) || !s.Tagged.Equal(want) {
+		t.Errorf("expected %v, got %v and %v", want, s.Date, s.Tagged)
+	}
+	if err := d.Decode(&s, map[string][]string{"date": {"2024-03-01"}}); err == nil {
+		t.Error("expected the decoder layout to replace the default layouts")
+	}
+
+	d.SetTimeLayout("")
+	if err := d.Decode(&s, map[string][]string{"date": {"2024-03-01"}}); err != nil {
+		t.Errorf("expected the default layouts to be restored, got %v", err)
+	}
+}
+
+func TestDefaultsOnConvertedTypes(t *testing.T) {
+	type point struct{ X, Y int }
+	type S struct {
+		Addr    netip.Addr    `schema:"addr,default:127.0.0.1"`
+		Addrs   []netip.Addr  `schema:"addrs,default:10.0.0.1|10.0.0.2"`
+		AddrPtr *netip.Addr   `schema:"addr_ptr,default:::1"`
+		Timeout time.Duration `schema:"timeout,default:1m30s"`
+		Day     time.Time     `schema:"day,layout:2006-01-02,default:2024-02-29"`
+		Origin  point         `schema:"origin,default:1;2"`
+	}
+	decoder := NewDecoder()
+	RegisterConverterFunc(decoder, func(s string) (point, error) {
+		x, y, _ := strings.Cut(s, ";")
+		px, err := strconv.Atoi(x)
+		if err != nil {
+			return point{}, err
+		}
+		py, err := strconv.Atoi(y)
+		return point{px, py}, err
+	})
+
+	var s S
+	if err := decoder.Decode(&s, map[string][]string{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Addr != netip.MustParseAddr("127.0.0.1") {
+		t.Errorf("Addr: got %v", s.Addr)
+	}
+	if len(s.Addrs) != 2 || s.Addrs[1] != netip.MustParseAddr("10.0.0.2") {
+		t.Errorf("Addrs: got %v", s.Addrs)
+	}
+	if s.AddrPtr == nil || *s.AddrPtr != netip.IPv6Loopback() {
+		t.Errorf("AddrPtr: got %v", s.AddrPtr)
+	}
+	if s.Timeout != 90*time.Second {
+		t.Errorf("Timeout: got %v", s.Timeout)
+	}
+	if !s.Day.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Day: got %v", s.Day)
+	}
+	if s.Origin != (point{1, 2}) {
+		t.Errorf("Origin: got %v", s.Origin)
+	}
+
+	// Defaults are copied, not shared between decoded values.
+	s.Addrs[0] = netip.Addr{}
+	var s2 S
+	if err := decoder.Decode(&s2, map[string][]string{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s2.Addrs[0] != netip.MustParseAddr("10.0.0.1") {
+		t.Errorf("default slice was shared: %v", s2.Addrs)
+	}
+}
+
+func TestInvalidDefaultReportedByPrepare(t *testing.T) {
+	type S struct {
+		Addr netip.Addr `schema:"addr,default:not-an-ip"`
+	}
+	decoder := NewDecoder()
+	err := decoder.Prepare(S{})
+	e, ok := err.(MultiError)
+	key := reflect.TypeFor[S]().String() + ".Addr"
+	if !ok || e[key] == nil {
+		t.Fatalf("expected the default error under %s, got %v", key, err)
+	}
+
+	// Decode ignores the invalid default, provided or not.
+	for _, src := range []map[string][]string{{"addr": {"10.0.0.1"}}, {}} {
+		var s S
+		if err := decoder.Decode(&s, src); err != nil {
+			t.Fatalf("unexpected error for %v: %v", src, err)
+		}
+		if want := src["addr"]; len(want) > 0 && s.Addr != netip.MustParseAddr(want[0]) {
+			t.Errorf("Addr: got %v", s.Addr)
+		}
+		if len(src) == 0 && s.Addr.IsValid() {
+			t.Errorf("invalid default applied: %v", s.Addr)
+		}
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	type Item struct {
+		SKU string `schema:"sku,required,default:x"`
+		Qty int    `schema:"qty,default:many"`
+	}
+	type Inner struct {
+		Note string `schema:"note,min:abc"`
+	}
+	type Order struct {
+		Name  string `schema:"name,required_with:missing"`
+		Inner Inner  `schema:"inner"`
+		Items []Item `schema:"items"`
+	}
+	type Valid struct {
+		Name  string `schema:"name,required"`
+		Inner struct {
+			Qty int `schema:"qty,default:1"`
+		} `schema:"inner"`
+	}
+
+	decoder := NewDecoder()
+	if err := decoder.Prepare(Valid{}, &Valid{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info := decoder.cache.get(reflect.TypeFor[Valid]())
+	if _, ok := info.paths.Load("inner.qty"); !ok {
+		t.Error("expected the path inner.qty to be cached")
+	}
+
+	err := decoder.Prepare(Order{}, 42)
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+	want := []string{
+		"int",
+		"schema.Order.Inner.Note",
+		"schema.Order.Items.Qty",
+		"schema.Order.Items.SKU",
+		"schema.Order.Name",
+	}
+	if got := errs.Paths(); !slices.Equal(got, want) {
+		t.Fatalf("expected errors for %v, got %v", want, errs.FullError())
+	}
+	if got := errs["schema.Order.Items.SKU"].Error(); got != "required fields cannot have a default value" {
+		t.Errorf("unexpected error %q", got)
+	}
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	type S struct {
+		Name  string   `schema:"name"`
+		Age   *int     `schema:"age"`
+		Tags  []string `schema:"tags"`
+		Inner struct {
+			ID int `schema:"id"`
+		} `schema:"inner"`
+	}
+	src := map[string][]string{
+		"name":     {"first", "last"},
+		"age":      {"1", "2"},
+		"tags":     {"a", "b"},
+		"inner.id": {"3"},
+	}
+
+	decoder := NewDecoder()
+	var last S
+	if err := decoder.Decode(&last, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last.Name != "last" || *last.Age != 2 {
+		t.Errorf("DuplicateLast: got %q and %d", last.Name, *last.Age)
+	}
+
+	decoder.SetDuplicatePolicy(DuplicateFirst)
+	var first S
+	if err := decoder.Decode(&first, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Name != "first" || *first.Age != 1 || len(first.Tags) != 2 {
+		t.Errorf("DuplicateFirst: got %q, %d and %v", first.Name, *first.Age, first.Tags)
+	}
+
+	decoder.SetDuplicatePolicy(DuplicateError)
+	var strict S
+	err := decoder.Decode(&strict, src)
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+	if got := errs.Paths(); !slices.Equal(got, []string{"age", "name"}) {
+		t.Fatalf("expected errors for age and name, got %v", errs.FullError())
+	}
+	var dup DuplicateValueError
+	if !errors.As(errs["name"], &dup) || dup.Key != "name" || dup.Count != 2 {
+		t.Errorf("unexpected error %#v", errs["name"])
+	}
+	if strict.Inner.ID != 3 || len(strict.Tags) != 2 {
+		t.Errorf("single values and slices should decode: %+v", strict)
+	}
+}
+
+func TestDuplicatePolicyRequiredIf(t *testing.T) {
+	type S struct {
+		Method  string `schema:"method"`
+		Address string `schema:"address,required_if:method=post"`
+	}
+	decoder := NewDecoder()
+	decoder.SetDuplicatePolicy(DuplicateFirst)
+	var s S
+	err := decoder.Decode(&s, map[string][]string{"method": {"post", "pickup"}})
+	var errs MultiError
+	if !errors.As(err, &errs) || errs["address"] == nil {
+		t.Fatalf("expected address to be required, got %v", err)
+	}
+}
+
+func TestCaseSensitive(t *testing.T) {
+	type Inner struct {
+		ID int `schema:"id"`
+	}
+	type S struct {
+		Name  string `schema:"name"`
+		Inner Inner  `schema:"Inner"`
+	}
+	src := map[string][]string{
+		"name":     {"lower"},
+		"Name":     {"upper"},
+		"Inner.id": {"1"},
+		"inner.ID": {"2"},
+	}
+
+	decoder := NewDecoder()
+	decoder.CaseSensitive(true)
+	var s S
+	err := decoder.Decode(&s, src)
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+	if got := errs.Paths(); !slices.Equal(got, []string{"Name", "inner.ID"}) {
+		t.Fatalf("expected unknown keys Name and inner.ID, got %v", errs.FullError())
+	}
+	if _, ok := errs["Name"].(UnknownKeyError); !ok {
+		t.Errorf("expected UnknownKeyError, got %#v", errs["Name"])
+	}
+	if s.Name != "lower" || s.Inner.ID != 1 {
+		t.Errorf("unexpected result %+v", s)
+	}
+
+	decoder.IgnoreUnknownKeys(true)
+	if err := decoder.Decode(&S{}, src); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Switching back matches keys regardless of case again.
+	decoder.CaseSensitive(false)
+	decoder.IgnoreUnknownKeys(false)
+	s = S{}
+	if err := decoder.Decode(&s, map[string][]string{"NAME": {"x"}, "inner.id": {"3"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Name != "x" || s.Inner.ID != 3 {
+		t.Errorf("unexpected result %+v", s)
+	}
+}
+
+type conflictBase struct {
+	ID   int `schema:"id"`
+	Name string
+	Note string
+}
+
+type conflictOther struct {
+	ID   int    `schema:"id"`
+	Note string `schema:"note"`
+}
+
+type conflictForm struct {
+	conflictBase
+	conflictOther
+	Code string `schema:"name"`
+	A    string `schema:"a"`
+	B    string `schema:"A"`
+}
+
+func TestAliasConflicts(t *testing.T) {
+	src := map[string][]string{
+		"id":   {"1"},
+		"name": {"code"},
+		"note": {"note"},
+		"a":    {"a"},
+	}
+
+	decoder := NewDecoder()
+	var f conflictForm
+	err := decoder.Decode(&f, src)
+	var errs MultiError
+	if !errors.As(err, &errs) || len(errs) != 1 || errs["id"] == nil {
+		t.Fatalf("expected only id to be unknown, got %v", err)
+	}
+	if f.Code != "code" || f.Name != "" || f.conflictOther.Note != "note" || f.conflictBase.Note != "" || f.A != "a" || f.B != "" {
+		t.Errorf("dominant fields should get the aliases: %+v", f)
+	}
+
+	err = decoder.Prepare(conflictForm{})
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+	want := []string{"schema.conflictForm.Note", "schema.conflictForm.a", "schema.conflictForm.id", "schema.conflictForm.name"}
+	if got := errs.Paths(); !slices.Equal(got, want) {
+		t.Fatalf("expected errors for %v, got %v", want, errs.FullError())
+	}
+	var conflict AliasConflictError
+	if !errors.As(errs["schema.conflictForm.id"], &conflict) || conflict.Dominant != "" ||
+		!slices.Equal(conflict.Fields, []string{"conflictBase.ID", "conflictOther.ID"}) {
+		t.Errorf("unexpected id conflict %#v", errs["schema.conflictForm.id"])
+	}
+	if !errors.As(errs["schema.conflictForm.name"], &conflict) || conflict.Dominant != "Code" {
+		t.Errorf("unexpected name conflict %#v", errs["schema.conflictForm.name"])
+	}
+	if !errors.As(errs["schema.conflictForm.Note"], &conflict) || conflict.Dominant != "conflictOther.Note" {
+		t.Errorf("unexpected note conflict %#v", errs["schema.conflictForm.Note"])
+	}
+	const msg = `schema: ambiguous alias "id" of schema.conflictForm shared by fields conflictBase.ID, conflictOther.ID`
+	if got := errs["schema.conflictForm.id"].Error(); got != msg {
+		t.Errorf("expected %q, got %q", msg, got)
+	}
+
+	decoder.ReportAliasConflicts(true)
+	f = conflictForm{}
+	err = decoder.Decode(&f, src)
+	if !errors.As(err, &errs) || len(errs) != 4 {
+		t.Fatalf("expected the conflicts, got %v", err)
+	}
+	if f.Code != "" {
+		t.Errorf("nothing should be decoded: %+v", f)
+	}
+
+	type Nested struct {
+		Items []conflictForm `schema:"items"`
+	}
+	err = decoder.Decode(&Nested{}, map[string][]string{})
+	if !errors.As(err, &errs) || errs["schema.Nested.Items.id"] == nil {
+		t.Fatalf("expected the conflicts of the elements, got %v", err)
+	}
+}
+
+func TestInterfaceFields(t *testing.T) {
+	type Item struct {
+		Meta any `schema:"meta"`
+	}
+	type S struct {
+		Single any         `schema:"single"`
+		Multi  interface{} `schema:"multi"`
+		Extra  any         `schema:"extra"`
+		Items  []Item      `schema:"items"`
+	}
+	src := map[string][]string{
+		"single":         {"one"},
+		"multi":          {"a", "b"},
+		"extra":          {"ignored"},
+		"extra.color":    {"red"},
+		"extra.size.w":   {"10"},
+		"extra.size.h":   {"20", "30"},
+		"items.1.meta.k": {"v"},
+	}
+
+	var s S
+	if err := NewDecoder().Decode(&s, src); err == nil {
+		t.Fatal("expected unknown keys without the option")
+	}
+
+	decoder := NewDecoder()
+	decoder.InterfaceFields(true)
+	s = S{}
+	if err := decoder.Decode(&s, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Single != "one" {
+		t.Errorf("Single: got %#v", s.Single)
+	}
+	if !reflect.DeepEqual(s.Multi, []string{"a", "b"}) {
+		t.Errorf("Multi: got %#v", s.Multi)
+	}
+	wantExtra := map[string]any{
+		"color": "red",
+		"size":  map[string]any{"w": "10", "h": []string{"20", "30"}},
+	}
+	if !reflect.DeepEqual(s.Extra, wantExtra) {
+		t.Errorf("Extra: got %#v", s.Extra)
+	}
+	if len(s.Items) != 2 || !reflect.DeepEqual(s.Items[1].Meta, map[string]any{"k": "v"}) {
+		t.Errorf("Items: got %#v", s.Items)
+	}
+
+	for _, key := range []string{"extra.", "extra..a", "extra.a."} {
+		if err := decoder.Decode(&S{}, map[string][]string{key: {"x"}}); err == nil {
+			t.Errorf("expected an error for %q", key)
+		}
+	}
+}
+
+func TestNullValue(t *testing.T) {
+	type Inner struct {
+		N int `schema:"n"`
+	}
+	type S struct {
+		I     *int     `schema:"i"`
+		S     *string  `schema:"s"`
+		Inner *Inner   `schema:"inner"`
+		Ptrs  []*int   `schema:"ptrs"`
+		Items []*Inner `schema:"items"`
+		E     *string  `schema:"e"`
+	}
+	one := 1
+	src := map[string][]string{
+		"i":       {"null"},
+		"s":       {"null"},
+		"inner":   {"null"},
+		"ptrs":    {"1", "null"},
+		"items.0": {"null"},
+		"items.1": {"null"},
+		"e":       {""},
+	}
+
+	decoder := NewDecoder()
+	decoder.SetNullValue("null")
+	s := S{I: &one, Inner: &Inner{N: 1}}
+	if err := decoder.Decode(&s, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.I != nil || s.S != nil || s.Inner != nil {
+		t.Errorf("expected nil pointers, got %+v", s)
+	}
+	if len(s.Ptrs) != 2 || *s.Ptrs[0] != 1 || s.Ptrs[1] != nil {
+		t.Errorf("Ptrs: got %v", s.Ptrs)
+	}
+	if len(s.Items) != 2 || s.Items[0] != nil || s.Items[1] != nil {
+		t.Errorf("Items: got %v", s.Items)
+	}
+	if s.E == nil || *s.E != "" {
+		t.Errorf("E: expected a pointer to an empty string, got %v", s.E)
+	}
+
+	decoder.EmptyAsNull(true)
+	s = S{}
+	if err := decoder.Decode(&s, map[string][]string{"e": {""}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.E != nil {
+		t.Errorf("E: expected nil, got %v", *s.E)
+	}
+
+	// Without a null value, "null" is a string.
+	s = S{}
+	if err := NewDecoder().Decode(&s, map[string][]string{"s": {"null"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.S == nil || *s.S != "null" {
+		t.Errorf("S: got %v", s.S)
+	}
+}
+
+func TestNullValueRoundTrip(t *testing.T) {
+	type Inner struct {
+		N int `schema:"n"`
+	}
+	type S struct {
+		I     *int    `schema:"i"`
+		S     *string `schema:"s"`
+		Inner *Inner  `schema:"inner"`
+		Ptrs  []*int  `schema:"ptrs"`
+	}
+	one := 1
+	src := S{I: nil, S: nil, Inner: nil, Ptrs: []*int{&one, nil}}
+
+	encoder := NewEncoder()
+	encoder.SetNullValue("~")
+	vals := map[string][]string{}
+	if err := encoder.Encode(src, vals); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	valExists(t, "i", "~", vals)
+	valExists(t, "inner", "~", vals)
+
+	decoder := NewDecoder()
+	decoder.SetNullValue("~")
+	dst := S{I: &one, Inner: &Inner{}}
+	if err := decoder.Decode(&dst, vals); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(src, dst) {
+		t.Errorf("expected %+v, got %+v", src, dst)
+	}
+}
+
+func TestDecodeWithResult(t *testing.T) {
+	type Base struct {
+		ID int `schema:"id"`
+	}
+	type Item struct {
+		SKU string `schema:"sku"`
+		Qty int    `schema:"qty,default:1"`
+	}
+	type Address struct {
+		City    string `schema:"city"`
+		Country string `schema:"country,default:NL"`
+	}
+	type Patch struct {
+		Base
+		Name    string            `schema:"name"`
+		Age     int               `schema:"age"`
+		Note    string            `schema:"note"`
+		Address Address           `schema:"addr"`
+		Items   []Item            `schema:"items"`
+		Attrs   map[string]string `schema:"attrs"`
+	}
+	src := map[string][]string{
+		"NAME":         {"Ann"},
+		"id":           {"7"},
+		"age":          {""},
+		"addr.city":    {"Delft"},
+		"items.1.sku":  {"b"},
+		"attrs.color":  {"red"},
+		"Base.id":      {"7"},
+		"unknown.path": {"x"},
+	}
+
+	decoder := NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	decoder.ZeroEmpty(true)
+	p := Patch{Age: 30}
+	res, err := decoder.DecodeWithResult(&p, src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Age != 0 || p.Name != "Ann" || p.ID != 7 {
+		t.Errorf("unexpected value: %+v", p)
+	}
+	// "id" and "Base.id" share the canonical path of the promoted field.
+	if want := []string{"Base.id", "addr.city", "attrs.color", "items.1.sku", "name"}; !slices.Equal(res.Provided, want) {
+		t.Errorf("Provided: expected %v, got %v", want, res.Provided)
+	}
+	if want := []string{"addr.country", "items.1.qty"}; !slices.Equal(res.Defaulted, want) {
+		t.Errorf("Defaulted: expected %v, got %v", want, res.Defaulted)
+	}
+	if want := []string{"age"}; !slices.Equal(res.Zeroed, want) {
+		t.Errorf("Zeroed: expected %v, got %v", want, res.Zeroed)
+	}
+	if want := []string{"Address.City", "Address.Country", "Age", "Attrs", "Base.ID", "Items", "Name"}; !slices.Equal(res.FieldMask(), want) {
+		t.Errorf("FieldMask: expected %v, got %v", want, res.FieldMask())
+	}
+
+	// Fields absent from src are in no list; an error still returns the
+	// fields decoded.
+	p = Patch{}
+	res, err = decoder.DecodeWithResult(&p, map[string][]string{"age": {"x"}, "note": {"hi"}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if want := []string{"note"}; !slices.Equal(res.Provided, want) || len(res.Zeroed) != 0 {
+		t.Errorf("expected only note provided, got %+v", res)
+	}
+	if want := []string{"Address.Country", "Note"}; !slices.Equal(res.FieldMask(), want) {
+		t.Errorf("FieldMask: expected %v, got %v", want, res.FieldMask())
+	}
+
+	if _, err := decoder.DecodeWithResult(p, src); err == nil {
+		t.Error("expected an error for a non-pointer destination")
+	}
+}
+
+func TestDecodeWithResultEmptyValues(t *testing.T) {
+	type S struct {
+		Name string        `schema:"name"`
+		Age  int           `schema:"age"`
+		Nick *string       `schema:"nick"`
+		Addr netip.Addr    `schema:"addr"`
+		Tags []string      `schema:"tags"`
+		Last Optional[int] `schema:"last"`
+	}
+
+	// Without ZeroEmpty, empty values leave single values unchanged.
+	s := S{Name: "keep", Age: 7}
+	res, err := NewDecoder().DecodeWithResult(&s, map[string][]string{"name": {""}, "age": {""}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Name != "keep" || s.Age != 7 {
+		t.Errorf("unexpected value: %+v", s)
+	}
+	if len(res.Provided) != 0 || len(res.Zeroed) != 0 || len(res.FieldMask()) != 0 {
+		t.Errorf("expected no field written, got %+v and %v", res, res.FieldMask())
+	}
+
+	// The other fields are written by empty values.
+	res, err = NewDecoder().DecodeWithResult(&s, map[string][]string{
+		"nick": {""},
+		"addr": {""},
+		"tags": {""},
+		"last": {""},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"addr", "last", "nick", "tags"}; !slices.Equal(res.Provided, want) {
+		t.Errorf("Provided: expected %v, got %v", want, res.Provided)
+	}
+}
+
+func TestOptional(t *testing.T) {
+	type point struct{ X, Y int }
+	type S struct {
+		Age    Optional[int]        `schema:"age"`
+		Name   Optional[string]     `schema:"name,required"`
+		Nick   Optional[string]     `schema:"nick"`
+		Addr   Optional[netip.Addr] `schema:"addr"`
+		At     Optional[point]      `schema:"at"`
+		Tags   Optional[[]string]   `schema:"tags"`
+		Plan   Optional[string]     `schema:"plan,default:free"`
+		Level  Optional[*int]       `schema:"level,default:3"`
+		Gone   Optional[int]        `schema:"gone"`
+		Rating Optional[int]        `schema:"rating,max:5"`
+	}
+	decoder := NewDecoder()
+	decoder.SetNullValue("null")
+	RegisterConverterFunc(decoder, func(s string) (point, error) {
+		xs, ys, _ := strings.Cut(s, ",")
+		x, err := strconv.Atoi(xs)
+		if err != nil {
+			return point{}, err
+		}
+		y, err := strconv.Atoi(ys)
+		return point{x, y}, err
+	})
+
+	src := map[string][]string{
+		"age":    {"0"},
+		"name":   {"Ann"},
+		"nick":   {"null"},
+		"addr":   {"10.0.0.1"},
+		"at":     {"1,2"},
+		"tags":   {"a", "b"},
+		"rating": {"4"},
+	}
+	s := S{Nick: Some("old")}
+	if err := decoder.Decode(&s, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, ok := s.Age.Get(); !ok || v != 0 {
+		t.Errorf("Age: expected a present 0, got %+v", s.Age)
+	}
+	if s.Name != Some("Ann") {
+		t.Errorf("Name: got %+v", s.Name)
+	}
+	if !s.Nick.Present || !s.Nick.Null || s.Nick.Value != "" {
+		t.Errorf("Nick: expected null, got %+v", s.Nick)
+	}
+	if s.Addr != Some(netip.MustParseAddr("10.0.0.1")) {
+		t.Errorf("Addr: got %+v", s.Addr)
+	}
+	if s.At != Some(point{1, 2}) {
+		t.Errorf("At: got %+v", s.At)
+	}
+	if !s.Tags.Present || !slices.Equal(s.Tags.Value, []string{"a", "b"}) {
+		t.Errorf("Tags: got %+v", s.Tags)
+	}
+	if s.Plan != Some("free") {
+		t.Errorf("Plan: expected the default, got %+v", s.Plan)
+	}
+	if !s.Level.Present || s.Level.Value == nil || *s.Level.Value != 3 {
+		t.Errorf("Level: expected the default, got %+v", s.Level)
+	}
+	if _, ok := s.Gone.Get(); ok || s.Gone.Present {
+		t.Errorf("Gone: expected absent, got %+v", s.Gone)
+	}
+
+	// A present value, zero or not, is kept over the default.
+	s = S{}
+	if err := decoder.Decode(&s, map[string][]string{"name": {"Ann"}, "plan": {""}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !s.Plan.Present || s.Plan.Value != "" {
+		t.Errorf("Plan: expected a present empty value, got %+v", s.Plan)
+	}
+
+	err := decoder.Decode(&S{}, map[string][]string{"age": {"x"}, "rating": {"6"}, "gone.value": {"1"}})
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	var emptyErr EmptyFieldError
+	if !errors.As(errs["name"], &emptyErr) {
+		t.Errorf("name: expected an EmptyFieldError, got %v", errs["name"])
+	}
+	var convErr ConversionError
+	if !errors.As(errs["age"], &convErr) || convErr.Type != reflect.TypeFor[int]() {
+		t.Errorf("age: expected a ConversionError for int, got %v", errs["age"])
+	}
+	var ruleErr ValidationError
+	if !errors.As(errs["rating"], &ruleErr) || ruleErr.Rule != "max" {
+		t.Errorf("rating: expected a max ValidationError, got %v", errs["rating"])
+	}
+	if _, ok := errs["gone.value"]; !ok {
+		t.Error("gone.value: expected an unknown key")
+	}
+}
+
+func TestSQLNullTypes(t *testing.T) {
+	type S struct {
+		Name   sql.NullString  `schema:"name"`
+		Age    sql.NullInt64   `schema:"age"`
+		Score  sql.NullFloat64 `schema:"score,max:10"`
+		Admin  sql.NullBool    `schema:"admin"`
+		Since  sql.NullTime    `schema:"since,layout:2006-01-02"`
+		Level  sql.Null[int16] `schema:"level,default:2"`
+		Nick   sql.NullString  `schema:"nick,required"`
+		Absent sql.NullString  `schema:"absent"`
+	}
+	decoder := NewDecoder()
+	decoder.SetNullValue("null")
+
+	s := S{Age: sql.NullInt64{Int64: 5, Valid: true}}
+	err := decoder.Decode(&s, map[string][]string{
+		"name":  {"Ann"},
+		"age":   {"null"},
+		"score": {"9.5"},
+		"admin": {"on"},
+		"since": {"2024-05-01"},
+		"nick":  {"annie"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := S{
+		Name:  sql.NullString{String: "Ann", Valid: true},
+		Score: sql.NullFloat64{Float64: 9.5, Valid: true},
+		Admin: sql.NullBool{Bool: true, Valid: true},
+		Since: sql.NullTime{Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+		Level: sql.Null[int16]{V: 2, Valid: true},
+		Nick:  sql.NullString{String: "annie", Valid: true},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("expected %+v, got %+v", want, s)
+	}
+
+	err = decoder.Decode(&S{}, map[string][]string{"name": {""}, "age": {"x"}, "score": {"11"}, "nick": {""}, "absent.valid": {"true"}})
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	if _, ok := errs["name"]; ok {
+		t.Errorf("name: expected an empty value to be invalid, got %v", errs["name"])
+	}
+	var convErr ConversionError
+	if !errors.As(errs["age"], &convErr) || convErr.Type != reflect.TypeFor[int64]() {
+		t.Errorf("age: expected a ConversionError for int64, got %v", errs["age"])
+	}
+	var ruleErr ValidationError
+	if !errors.As(errs["score"], &ruleErr) || ruleErr.Rule != "max" {
+		t.Errorf("score: expected a max ValidationError, got %v", errs["score"])
+	}
+	var emptyErr EmptyFieldError
+	if !errors.As(errs["nick"], &emptyErr) {
+		t.Errorf("nick: expected an EmptyFieldError, got %v", errs["nick"])
+	}
+	if _, ok := errs["absent.valid"]; !ok {
+		t.Error("absent.valid: expected an unknown key")
+	}
+}
+
+type enumStatus string
+
+type enumLevel int
+
+func TestRegisterEnum(t *testing.T) {
+	type S struct {
+		Status enumStatus          `schema:"status"`
+		Level  enumLevel           `schema:"level,default:low"`
+		Levels []enumLevel         `schema:"levels"`
+		Ptr    *enumStatus         `schema:"ptr"`
+		Opt    Optional[enumLevel] `schema:"opt"`
+	}
+	decoder := NewDecoder()
+	RegisterEnum(decoder, map[string]enumStatus{"active": "A", "disabled": "D"})
+	RegisterEnum(decoder, map[string]enumLevel{"low": 1, "high": 2}, EnumCaseInsensitive)
+
+	var s S
+	err := decoder.Decode(&s, map[string][]string{"status": {"active"}, "levels": {"HIGH", "Low"}, "ptr": {"disabled"}, "opt": {"high"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Status != "A" || s.Level != 1 || !slices.Equal(s.Levels, []enumLevel{2, 1}) || s.Ptr == nil || *s.Ptr != "D" || s.Opt != Some[enumLevel](2) {
+		t.Errorf("unexpected value: %+v", s)
+	}
+
+	err = decoder.Decode(&S{}, map[string][]string{"status": {"Active"}})
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	var convErr ConversionError
+	if !errors.As(errs["status"], &convErr) {
+		t.Fatalf("expected a ConversionError, got %v", errs["status"])
+	}
+	var enumErr EnumValueError
+	if !errors.As(convErr.Err, &enumErr) || enumErr.Value != "Active" || !slices.Equal(enumErr.Allowed, []string{"active", "disabled"}) {
+		t.Errorf("expected an EnumValueError listing the names, got %v", convErr.Err)
+	}
+	if want := `"Active" is not one of active, disabled`; !strings.Contains(err.Error(), want) {
+		t.Errorf("expected the error to contain %q, got %q", want, err.Error())
+	}
+
+	enums := decoder.Enums()
+	if len(enums) != 2 {
+		t.Fatalf("expected 2 enums, got %d", len(enums))
+	}
+	if enums[0].Type != reflect.TypeFor[enumLevel]() || !enums[0].CaseInsensitive || !slices.Equal(enums[0].Names, []string{"high", "low"}) || enums[0].Values["high"] != enumLevel(2) {
+		t.Errorf("unexpected enum: %+v", enums[0])
+	}
+	if enums[1].Type != reflect.TypeFor[enumStatus]() || enums[1].CaseInsensitive {
+		t.Errorf("unexpected enum: %+v", enums[1])
+	}
+
+	// A converter registered later replaces the enum.
+	decoder.RegisterConverter(enumStatus(""), func(s string) reflect.Value {
+		return reflect.ValueOf(enumStatus(s))
+	})
+	if n := len(decoder.Enums()); n != 1 {
+		t.Errorf("expected 1 enum, got %d", n)
+	}
+}
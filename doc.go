@@ -65,9 +65,22 @@ The supported field types in the destination struct are:
   - int variants (int, int8, int16, int32, int64)
   - string
   - uint variants (uint, uint8, uint16, uint32, uint64)
+  - time.Time and time.Duration
   - struct
   - a pointer to one of the above types
   - a slice or a pointer to a slice of one of the above types
+  - an array of one of the above types, filled like a slice; more values
+    than the array length are a ConversionError
+  - a map (or a pointer to a map) whose keys are convertible and whose
+    values are one of the above types
+  - any (interface{}), when Decoder.InterfaceFields is set: a string for one
+    value, a []string for several, and nested map[string]any values for the
+    dotted paths below the field
+  - Optional[T] of the above types, other than structs and maps, which also
+    records whether the key was present and held the null value
+  - the Null types of database/sql, like sql.NullString, sql.NullTime and
+    sql.Null[T], decoded as their value, or as invalid for an empty or null
+    value, and encoded as an empty value when invalid
 
 Non-supported types are simply ignored, however custom types can be registered
 to be converted.
@@ -86,6 +99,8 @@ field. So for example, to fill the struct Person below:
 	}
 
 ...the source map must have the keys "Name", "Phone.Label" and "Phone.Number".
+Keys match field names and aliases regardless of case, unless
+Decoder.CaseSensitive is set.
 This means that an HTML form to fill a Person struct must look like this:
 
 	<form>
@@ -94,8 +109,23 @@ This means that an HTML form to fill a Person struct must look like this:
 		<input type="text" name="Phone.Number">
 	</form>
 
-Single values are filled using the first value for a key from the source map.
-Slices are filled using all values for a key from the source map. So to fill
+The Encoder encodes nil pointers as "null", or the value set with
+Encoder.SetNullValue. Decoder.SetNullValue makes the decoder set pointers to
+nil for that value, so the output of the Encoder decodes back without loss,
+and Decoder.EmptyAsNull does the same for empty values.
+
+When fields share an alias, directly or promoted from embedded structs, the
+rules of encoding/json pick the field that gets it: the least nested one,
+then the one with an alias tag; with no such field, embedded fields are
+ignored. The Encoder follows the same rules, comparing aliases as is, and
+does not encode the embedded fields ignored. Decoder.Prepare reports these
+conflicts as AliasConflictErrors, and the ReportAliasConflicts option of the
+Decoder and Encoder makes Decode and Encode fail on them.
+
+Single values are filled using the last value for a key from the source map,
+or the first one, or several values are rejected with a DuplicateValueError,
+depending on Decoder.SetDuplicatePolicy. Slices are filled using all values
+for a key from the source map. So to fill
 a Person with multiple Phone values, like:
 
 	type Person struct {
@@ -120,6 +150,97 @@ This is needed for disambiguation: if the nested struct also had a slice
 field, we could not translate multiple values to it if we did not use an
 index for the parent struct.
 
+Clients that send keys in bracket notation, like PHP, Rails or jQuery's
+$.param ("Phones[0][Label]", "Tags[]"), are supported by enabling
+Decoder.BracketNotation; Encoder.BracketNotation produces such keys.
+
+Maps are filled entry by entry, using the map key as the path segment
+after the field name. So to fill the struct below:
+
+	type Product struct {
+		Attrs map[string]string
+		Sizes map[string]Size
+	}
+
+...the source map may have keys like "Attrs.color" and "Sizes.small.Width".
+For maps of non-struct values everything after the field name is the key,
+so "Attrs.a.b" sets the entry "a.b". Map keys are converted like field
+values, using a registered converter for the key type when there is one.
+
+Times are decoded from RFC 3339 values and the values of the HTML
+datetime-local, date and time inputs, unless a layout is set with
+Decoder.SetTimeLayout or per field with the "layout" tag option, which the
+Encoder also follows (it defaults to time.RFC3339Nano):
+
+	type Event struct {
+		Day   time.Time     `schema:"day,layout:2006-01-02"`
+		Start time.Time     `schema:"start"`
+		Span  time.Duration `schema:"span"` // "1h30m", or nanoseconds
+	}
+
+Layouts cannot contain commas, which separate tag options.
+
+The "required_if:Field=value", "required_unless:Field=value",
+"required_with:Field" and "required_without:Field" options require a field
+depending on a sibling, named by its alias or Go name; several conditions
+can be listed separated by "|", and any of them requiring the field is
+enough:
+
+	type Contact struct {
+		Email   string `schema:"email,required_without:phone"`
+		Phone   string `schema:"phone"`
+		Company bool   `schema:"company"`
+		VAT     string `schema:"vat,required_if:company=true"`
+	}
+
+The required options and defaults of the structs in a slice apply to every
+element present in the source, and errors are keyed by the element path,
+like "items.2.sku".
+
+Fields can declare validation rules as tag options: "min:N", "max:N" and
+"len:N" bound the length of strings, slices and maps, and min and max the
+value of numbers; "oneof:a|b", "pattern:regexp", "email" and "url" check
+scalars and each element of slices. The rules are checked after decoding,
+except on zero values (use required to reject those) other than the numbers
+present in the source, and failures are returned as ValidationErrors keyed
+by the path of the field:
+
+	type Signup struct {
+		Name  string `schema:"name,required,min:2,max:40"`
+		Plan  string `schema:"plan,oneof:free|pro"`
+		Email string `schema:"email,email"`
+	}
+
+Decoder.Prepare analyzes struct types ahead of the first Decode and reports
+the mistakes in their tags at once, such as invalid defaults, which Decode
+ignores, or invalid validation rules, which make Decode refuse the values
+of their field.
+
+Decoder.DecodeWithResult also reports the fields it wrote, by canonical
+path, split into those provided by the source, set to their default and set
+to zero by ZeroEmpty, and DecodeResult.FieldMask gives their Go paths, for
+PATCH handlers that must tell absent fields apart from zero values.
+
+Types implementing Validator are validated after decoding, as are the
+nested structs implementing it, and Decoder.SetValidator plugs in a
+validation engine, given the form path of every Go field path.
+
+Custom types are supported by registering a converter. RegisterConverterFunc
+takes a typed function whose error is reported in the Err field of the
+resulting ConversionError:
+
+	schema.RegisterConverterFunc(decoder, func(s string) (uuid.UUID, error) {
+		return uuid.Parse(s)
+	})
+
+Enum types are registered with their values by name. RegisterEnum reports
+the other values with an EnumValueError listing the names,
+RegisterEnumEncoder encodes values as their names, and Decoder.Enums
+describes the registered enums:
+
+	schema.RegisterEnum(decoder, map[string]Status{"active": Active, "disabled": Disabled})
+	schema.RegisterEnumEncoder(encoder, map[string]Status{"active": Active, "disabled": Disabled})
+
 There's also the possibility to create a custom type that implements the
 TextUnmarshaler interface, and in this case there's no need to register
 a converter, like:
@@ -144,5 +265,20 @@ a converter, like:
 		<input type="email" name="Emails.1">
 		<input type="email" name="Emails.2">
 	</form>
+
+Symmetrically, the Encoder encodes types implementing the TextMarshaler
+interface, like net.IP or netip.Addr, with their MarshalText method unless
+an encoder is registered for the type. Marshaling errors are returned in a
+MultiError under the key of the field.
+
+Decode reports all field errors at once in a MultiError, keyed by path. It is
+a map and can be ranged over, but Errors and All return the errors sorted by
+path, FullError formats every error on its own line, and errors.As reaches
+the ConversionError, EmptyFieldError or UnknownKeyError of any field:
+
+	var conv schema.ConversionError
+	if errors.As(err, &conv) {
+		// conv.Key failed to convert
+	}
 */
 package schema
@@ -1,8 +1,11 @@
 package schema
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
+	"maps"
+	"net/url"
 	"reflect"
 	"strconv"
 	"sync"
@@ -11,7 +14,14 @@ import (
 	utils "github.com/gofiber/utils/v2"
 )
 
-type encoderFunc func(reflect.Value) string
+// encoderFunc encodes a value; only encoding.TextMarshaler implementations
+// can fail.
+type encoderFunc func(reflect.Value) (string, error)
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// defaultNullValue is the default encoding of nil pointers.
+const defaultNullValue = "null"
 
 // errNotStruct is returned by Encode for invalid sources; hoisted so the
 // check does not allocate on every call.
@@ -34,6 +44,16 @@ type Encoder struct {
 	// the plan if it changed, so a build racing a reconfiguration cannot
 	// re-insert a stale plan after the clear.
 	encGen atomic.Uint64
+	// bracketNotation selects "a[0][b]" keys instead of "a.0.b".
+	bracketNotation bool
+	// timeLayout is the layout of time.Time fields without a "layout:" tag
+	// option; read and written under cache.l as it shapes the plans.
+	timeLayout string
+	// reportConflicts makes Encode fail on alias conflicts.
+	reportConflicts bool
+	// nullValue is the encoding of nil pointers; read and written under
+	// cache.l as it shapes the plans.
+	nullValue string
 }
 
 // encPlan tags a per-type encoding plan with the configuration generation it
@@ -43,33 +63,79 @@ type Encoder struct {
 type encPlan struct {
 	fields []encField
 	gen    uint64
+	// conflicts lists the keys shared by several fields, here and in
+	// embedded structs, and treeConflicts those of the whole struct tree,
+	// collected on first use.
+	conflicts     []AliasConflictError
+	conflictsOnce sync.Once
+	treeConflicts MultiError
 }
 
 // encField is the precomputed encoding plan for one struct field.
 type encField struct {
-	name      string
-	enc       encoderFunc // immediate encoder; nil for structs and slices
-	elemEnc   encoderFunc // slice element encoder, when the field is a slice
-	idx       int
+	name    string
+	null    string      // encoding of nil pointers
+	enc     encoderFunc // immediate encoder; nil for structs and slices
+	elemEnc encoderFunc // slice element encoder, when the field is a slice
+	// index is the field index chain in the struct; fields promoted from
+	// embedded structs carry the chain through them.
+	index []int
+	// goPath is the Go path of the field, through the embedded structs it
+	// is promoted from ("Base.ID"), and tagged reports whether its name is
+	// set by the alias tag.
+	goPath    string
+	tagged    bool
 	omitEmpty bool
+	// optional marks Optional fields, encoded as their value when present.
+	optional bool
+	// sqlNull marks fields of the database/sql Null types, encoded as their
+	// value when valid, and as an empty value otherwise, which decodes back
+	// to an invalid value.
+	sqlNull bool
 	// recurseStructPtr marks pointer-to-struct fields without a custom
 	// encoder: non-nil values are encoded by recursing into the element.
 	recurseStructPtr bool
 	isStruct         bool
+	// isAnonymous marks embedded fields. The fields of embedded structs are
+	// promoted into the plan, following the dominance rules of the decoder;
+	// an embedded struct of a recursive type is instead recursed, flattened
+	// into the parent's namespace.
+	isAnonymous bool
 	// nilAsNull marks pointer fields whose element has no immediate
 	// encoder (structs recursed via recurseStructPtr, or unsupported
 	// types): nil values encode as "null", matching the closure behavior
 	// pointer fields with encodable elements get.
 	nilAsNull bool
 	// elemPtrNil marks slice fields whose element is a pointer type with no
-	// encoder (e.g. []*Struct): nil elements encode as "null" (as they did
-	// historically), while a non-nil such element is an error.
+	// encoder (e.g. []*complex128): nil elements encode as "null" (as they
+	// did historically), while a non-nil such element is an error.
 	elemPtrNil bool
+	// structElems marks []Struct, []*Struct and *[]Struct fields (or the
+	// equivalent arrays) without a custom element encoder: each element is
+	// recursed under an indexed "alias.N." prefix, matching the decoder's
+	// slice-of-structs paths.
+	structElems bool
+	// mapEnc is the entry plan for map (and pointer to map) fields with an
+	// encodable key type; nil when the field is not such a map.
+	mapEnc *encMap
+}
+
+// encMap is the precomputed encoding plan for the entries of a map field,
+// each written under "alias.key" (the paths the decoder reads back). Exactly
+// one of valEnc, elemEnc and isStruct describes the value type.
+type encMap struct {
+	null    string // encoding of nil struct pointers
+	keyEnc  encoderFunc
+	valEnc  encoderFunc // scalar values
+	elemEnc encoderFunc // elements of slice values
+	// isStruct marks struct (or pointer to struct) values, recursed under
+	// "alias.key." prefixes.
+	isStruct bool
 }
 
 // NewEncoder returns a new Encoder with defaults.
 func NewEncoder() *Encoder {
-	return &Encoder{cache: newCache(), regenc: make(map[reflect.Type]encoderFunc)}
+	return &Encoder{cache: newCache(), regenc: make(map[reflect.Type]encoderFunc), nullValue: defaultNullValue}
 }
 
 // Encode encodes a struct into map[string][]string.
@@ -93,14 +159,41 @@ func (e *Encoder) Encode(src interface{}, dst map[string][]string) (err error) {
 	}()
 
 	v := reflect.ValueOf(src)
+	if e.reportConflicts && v.IsValid() {
+		if t := indirectType(v.Type()); t.Kind() == reflect.Struct {
+			if conflicts := e.aliasConflicts(t); len(conflicts) > 0 {
+				return maps.Clone(conflicts)
+			}
+		}
+	}
+
+	return e.encode(v, "", dst)
+}
 
-	return e.encode(v, dst)
+// EncodeValues encodes v, a struct or a pointer to a struct, into new
+// url.Values. As with Encode, the values are returned along with the error,
+// holding the fields that encoded successfully.
+func EncodeValues[T any](e *Encoder, v T) (url.Values, error) {
+	dst := make(url.Values)
+	return dst, e.Encode(v, dst)
 }
 
-// RegisterEncoder registers a converter for encoding a custom type.
+// RegisterEncoder registers a converter for encoding a custom type. It takes
+// precedence over an encoding.TextMarshaler implementation of the type.
 func (e *Encoder) RegisterEncoder(value interface{}, encoder func(reflect.Value) string) {
+	var enc encoderFunc
+	if encoder != nil {
+		enc = func(v reflect.Value) (string, error) {
+			return encoder(v), nil
+		}
+	}
+	e.registerEncoder(reflect.TypeOf(value), enc)
+}
+
+// registerEncoder registers enc as the encoder of t.
+func (e *Encoder) registerEncoder(t reflect.Type, enc encoderFunc) {
 	e.cache.l.Lock()
-	e.regenc[reflect.TypeOf(value)] = encoder
+	e.regenc[t] = enc
 	e.cache.l.Unlock()
 	e.encGen.Add(1)
 	e.encCache.Clear()
@@ -111,27 +204,100 @@ func (e *Encoder) RegisterEncoder(value interface{}, encoder func(reflect.Value)
 func (e *Encoder) SetAliasTag(tag string) {
 	e.cache.l.Lock()
 	e.cache.tag = tag
+	e.cache.reset()
 	e.cache.l.Unlock()
 	e.encGen.Add(1)
 	e.encCache.Clear()
 }
 
-// structInfo returns the cached encoding plan for struct type t, building it
-// on first use. The build reads the tag and registered encoders under the
+// SetTimeLayout sets the layout used to encode time.Time fields that have no
+// "layout:" tag option. The default layout, restored by passing an empty
+// layout, is time.RFC3339Nano.
+func (e *Encoder) SetTimeLayout(layout string) {
+	e.cache.l.Lock()
+	e.timeLayout = layout
+	e.cache.l.Unlock()
+	e.encGen.Add(1)
+	e.encCache.Clear()
+}
+
+// SetNullValue sets the value nil pointers are encoded as, "null" by
+// default, restored by passing an empty value. Decoder.SetNullValue decodes
+// it back to nil pointers.
+func (e *Encoder) SetNullValue(null string) {
+	if null == "" {
+		null = defaultNullValue
+	}
+	e.cache.l.Lock()
+	e.nullValue = null
+	e.cache.l.Unlock()
+	e.encGen.Add(1)
+	e.encCache.Clear()
+}
+
+// ReportAliasConflicts controls whether Encode fails when fields of the
+// source struct tree share an alias, directly or through embedded structs,
+// which would encode their values under the same key. If r is true, Encode
+// returns their AliasConflictErrors without encoding.
+//
+// The default value is false.
+func (e *Encoder) ReportAliasConflicts(r bool) {
+	e.reportConflicts = r
+}
+
+// BracketNotation controls the notation of the keys for nested fields.
+// If b is true, nested structs, slice elements and map entries are encoded
+// in bracket notation ("phones[0][label]", "attrs[color]") and multi-valued
+// slices under "tags[]", as PHP, Rails and jQuery's $.param expect.
+// If b is false, keys use dotted notation ("phones.0.label").
+//
+// The default value is false.
+func (e *Encoder) BracketNotation(b bool) {
+	e.bracketNotation = b
+}
+
+// structInfo returns the fields of the cached encoding plan for struct type
+// t, building it on first use.
+func (e *Encoder) structInfo(t reflect.Type) []encField {
+	return e.plan(t).fields
+}
+
+// plan returns the cached encoding plan for struct type t, building it on
+// first use. The build reads the tag and registered encoders under the
 // configuration lock; the generation re-checks around the cache store keep a
 // build racing a reconfiguration from inserting a stale plan.
-func (e *Encoder) structInfo(t reflect.Type) []encField {
+func (e *Encoder) plan(t reflect.Type) *encPlan {
 	gen := e.encGen.Load()
 	if cached, ok := e.encCache.Load(t); ok {
 		// Ignore plans built under an older configuration; fall through and
 		// rebuild (the fresh plan overwrites the stale entry).
 		if p := cached.(*encPlan); p.gen == gen {
-			return p.fields
+			return p
 		}
 	}
 	e.cache.l.RLock()
+	p := &encPlan{gen: gen}
+	p.fields, p.conflicts = e.buildFields(t, map[reflect.Type]bool{t: true})
+	e.cache.l.RUnlock()
+	// Don't cache a plan whose inputs (tag, registered encoders) changed
+	// while it was being built; the next call rebuilds it fresh. Even if a
+	// stale plan slips in after the clear, its generation tag keeps it from
+	// ever being served.
+	if e.encGen.Load() == gen {
+		e.encCache.Store(t, p)
+	}
+	return p
+}
+
+// buildFields returns the encoding plan of the fields of struct type t, with
+// the fields of its embedded structs promoted, and the names they share.
+// visiting holds the embedded types being built, whose fields are not
+// promoted again. Must be called with the configuration lock held.
+func (e *Encoder) buildFields(t reflect.Type, visiting map[reflect.Type]bool) ([]encField, []AliasConflictError) {
 	tag := e.cache.tag
+	null := e.nullValue
 	fields := make([]encField, 0, t.NumField())
+	var conflicts []AliasConflictError
 	for i := 0; i < t.NumField(); i++ {
 		sf := t.Field(i)
 		name, opts := fieldAlias(sf, tag)
@@ -139,39 +305,189 @@ func (e *Encoder) structInfo(t reflect.Type) []encField {
 			continue
 		}
 		ft := sf.Type
+		optional := isOptionalType(ft)
+		sqlNull := !optional && isSQLNullType(ft) && !e.hasCustomEncoder(ft)
+		if optional {
+			ft = ft.Field(optionalValue).Type
+		} else if sqlNull {
+			ft = ft.Field(sqlNullValue).Type
+		}
+		layout := opts.getLayoutOptionValue()
+		if layout == "" {
+			layout = e.timeLayout
+		}
+		tagName, _ := parseTag(sf.Tag.Get(tag))
 		f := encField{
-			idx:       i,
-			name:      name,
-			omitEmpty: opts.Contains("omitempty"),
+			index:       []int{i},
+			name:        name,
+			goPath:      sf.Name,
+			tagged:      tagName != "",
+			null:        null,
+			omitEmpty:   opts.Contains("omitempty"),
+			optional:    optional,
+			sqlNull:     sqlNull,
+			isAnonymous: sf.Anonymous,
 			recurseStructPtr: ft.Kind() == reflect.Ptr &&
 				ft.Elem().Kind() == reflect.Struct &&
-				!e.hasCustomEncoder(ft),
-			enc: typeEncoder(ft, e.regenc),
+				!e.hasCustomEncoder(ft) && !ft.Implements(textMarshalerType),
+			enc: typeEncoder(ft, e.regenc, layout, null),
 		}
 		if f.enc == nil {
 			switch ft.Kind() {
 			case reflect.Struct:
 				f.isStruct = true
-			case reflect.Slice:
-				f.elemEnc = typeEncoder(ft.Elem(), e.regenc)
-				if f.elemEnc == nil && ft.Elem().Kind() == reflect.Ptr {
-					f.elemPtrNil = true
+			case reflect.Slice, reflect.Array:
+				f.elemEnc = typeEncoder(ft.Elem(), e.regenc, layout, null)
+				if f.elemEnc == nil {
+					f.structElems = indirectType(ft.Elem()).Kind() == reflect.Struct
+					f.elemPtrNil = !f.structElems && ft.Elem().Kind() == reflect.Ptr
 				}
+			case reflect.Map:
+				f.mapEnc = e.mapPlan(ft, layout, null)
 			case reflect.Ptr:
 				f.nilAsNull = true
+				if st := ft.Elem(); (st.Kind() == reflect.Slice || st.Kind() == reflect.Array) && typeEncoder(st.Elem(), e.regenc, layout, null) == nil {
+					f.structElems = indirectType(st.Elem()).Kind() == reflect.Struct
+				} else if st.Kind() == reflect.Map {
+					f.mapEnc = e.mapPlan(st, layout, null)
+				}
 			}
 		}
+		if st := indirectType(ft); f.isAnonymous && !optional && !sqlNull && (f.isStruct || f.recurseStructPtr) && !visiting[st] {
+			visiting[st] = true
+			promoted, embeddedConflicts := e.buildFields(st, visiting)
+			delete(visiting, st)
+			for _, pf := range promoted {
+				pf.index = append([]int{i}, pf.index...)
+				pf.goPath = sf.Name + "." + pf.goPath
+				fields = append(fields, pf)
+			}
+			conflicts = append(conflicts, embeddedConflicts...)
+			continue
+		}
 		fields = append(fields, f)
 	}
-	e.cache.l.RUnlock()
-	// Don't cache a plan whose inputs (tag, registered encoders) changed
-	// while it was being built; the next call rebuilds it fresh. Even if a
-	// stale plan slips in after the clear, its generation tag keeps it from
-	// ever being served.
-	if e.encGen.Load() == gen {
-		e.encCache.Store(t, &encPlan{fields: fields, gen: gen})
+	return resolveEncFields(t, fields, conflicts)
+}
+
+// resolveEncFields drops the promoted fields of fields, those of struct type
+// t, that share their name with a dominant field or with no dominant field,
+// following the rules of the decoder, and adds the shared names to
+// conflicts. The fields of t itself are all kept, encoded under the same key.
+func resolveEncFields(t reflect.Type, fields []encField, conflicts []AliasConflictError) ([]encField, []AliasConflictError) {
+	var names []string
+	groups := make(map[string][]int, len(fields))
+	for i := range fields {
+		name := fields[i].name
+		if groups[name] == nil {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], i)
+	}
+	if len(names) == len(fields) {
+		return fields, conflicts
 	}
-	return fields
+	dropped := make([]bool, len(fields))
+	for _, name := range names {
+		group := groups[name]
+		if len(group) == 1 {
+			continue
+		}
+		dominant := dominantIndex(len(group), func(i int) (int, bool) {
+			f := &fields[group[i]]
+			return len(f.index), f.tagged
+		})
+		conflict := AliasConflictError{Type: t, Alias: name}
+		for i, j := range group {
+			conflict.Fields = append(conflict.Fields, fields[j].goPath)
+			dropped[j] = i != dominant && len(fields[j].index) > 1
+		}
+		if dominant >= 0 {
+			conflict.Dominant = fields[group[dominant]].goPath
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	kept := fields[:0]
+	for i := range fields {
+		if !dropped[i] {
+			kept = append(kept, fields[i])
+		}
+	}
+	return kept, conflicts
+}
+
+// aliasConflicts returns the names shared by several fields in the struct
+// tree of t, keyed like those reported by Decoder.Prepare. They are
+// collected once per plan.
+func (e *Encoder) aliasConflicts(t reflect.Type) MultiError {
+	p := e.plan(t)
+	p.conflictsOnce.Do(func() {
+		p.treeConflicts = e.collectConflicts(t, p, t.String()+".", map[reflect.Type]bool{t: true}, nil)
+	})
+	return p.treeConflicts
+}
+
+func (e *Encoder) collectConflicts(t reflect.Type, p *encPlan, goPrefix string, visited map[reflect.Type]bool, errs MultiError) MultiError {
+	for _, conflict := range p.conflicts {
+		errs = appendError(errs, goPrefix+conflict.Alias, conflict)
+	}
+	for i := range p.fields {
+		f := &p.fields[i]
+		elem := f.nestedStruct(t)
+		if elem == nil || visited[elem] {
+			continue
+		}
+		visited[elem] = true
+		errs = e.collectConflicts(elem, e.plan(elem), goPrefix+f.goPath+".", visited, errs)
+		delete(visited, elem)
+	}
+	return errs
+}
+
+// nestedStruct returns the struct type encoded under the key of f, a field
+// of struct type t: the type of a struct field, of the elements of a slice
+// of structs or of the values of a map of structs. It returns nil for
+// other fields and for embedded structs, flattened into t.
+func (f *encField) nestedStruct(t reflect.Type) reflect.Type {
+	if f.isAnonymous || f.optional || f.sqlNull {
+		return nil
+	}
+	ft := indirectType(t.FieldByIndex(f.index).Type)
+	switch {
+	case f.isStruct || f.recurseStructPtr:
+		return ft
+	case f.structElems:
+		return indirectType(ft.Elem())
+	case f.mapEnc != nil && f.mapEnc.isStruct:
+		return indirectType(ft.Elem())
+	}
+	return nil
+}
+
+// mapPlan returns the entry plan for map type t, or nil when its keys or
+// values cannot be encoded. Times are encoded in layout. Must be called with
+// the configuration lock held.
+func (e *Encoder) mapPlan(t reflect.Type, layout, null string) *encMap {
+	keyEnc := typeEncoder(t.Key(), e.regenc, layout, null)
+	if keyEnc == nil {
+		return nil
+	}
+	m := &encMap{keyEnc: keyEnc, null: null}
+	vt := t.Elem()
+	if m.valEnc = typeEncoder(vt, e.regenc, layout, null); m.valEnc != nil {
+		return m
+	}
+	switch {
+	case vt.Kind() == reflect.Slice:
+		if m.elemEnc = typeEncoder(vt.Elem(), e.regenc, layout, null); m.elemEnc == nil {
+			return nil
+		}
+	case indirectType(vt).Kind() == reflect.Struct:
+		m.isStruct = true
+	default:
+		return nil
+	}
+	return m
 }
 
 func isZero(v reflect.Value) bool {
@@ -203,7 +519,11 @@ func isZero(v reflect.Value) bool {
 	return v.IsZero()
 }
 
-func (e *Encoder) encode(v reflect.Value, dst map[string][]string) error {
+// encode writes the fields of struct v into dst. prefix is the path of v
+// relative to the root struct ("" at the root), so nested struct fields are
+// emitted under the same "Parent.Child" (or "Parent[Child]") keys the
+// decoder parses.
+func (e *Encoder) encode(v reflect.Value, prefix string, dst map[string][]string) error {
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
@@ -216,11 +536,38 @@ func (e *Encoder) encode(v reflect.Value, dst map[string][]string) error {
 	fields := e.structInfo(v.Type())
 	for i := range fields {
 		f := &fields[i]
-		fieldValue := v.Field(f.idx)
+		fieldValue := v.Field(f.index[0])
+		if len(f.index) > 1 {
+			var err error
+			if fieldValue, err = v.FieldByIndexErr(f.index); err != nil {
+				// A nil embedded struct pointer has no fields to encode.
+				continue
+			}
+		}
+		key := e.joinPath(prefix, f.name)
+
+		if f.optional {
+			if !fieldValue.Field(optionalPresent).Bool() {
+				continue
+			}
+			if fieldValue.Field(optionalNull).Bool() {
+				dst[key] = append(dst[key], f.null)
+				continue
+			}
+			fieldValue = fieldValue.Field(optionalValue)
+		} else if f.sqlNull {
+			if !fieldValue.Field(sqlNullValid).Bool() {
+				if !f.omitEmpty {
+					dst[key] = append(dst[key], "")
+				}
+				continue
+			}
+			fieldValue = fieldValue.Field(sqlNullValue)
+		}
 
 		// Encode struct pointer types if the field is a valid pointer and a struct.
 		if f.recurseStructPtr && !fieldValue.IsNil() {
-			if err := e.encode(fieldValue.Elem(), dst); err != nil {
+			if err := e.encode(fieldValue.Elem(), f.nestedPrefix(prefix, key), dst); err != nil {
 				errs = setError(errs, fieldValue.Elem().Type().String(), err)
 			}
 			continue
@@ -231,40 +578,57 @@ func (e *Encoder) encode(v reflect.Value, dst map[string][]string) error {
 			if f.omitEmpty && isZero(fieldValue) {
 				continue
 			}
-			dst[f.name] = append(dst[f.name], f.enc(fieldValue))
+			s, err := f.enc(fieldValue)
+			if err != nil {
+				errs = setError(errs, key, err)
+				continue
+			}
+			dst[key] = append(dst[key], s)
 			continue
 		}
 
 		if f.nilAsNull && fieldValue.IsNil() {
-			if f.omitEmpty {
+			// A nil embedded struct pointer has no fields to flatten into
+			// the parent, and its own alias is not a decodable key.
+			if f.omitEmpty || (f.isAnonymous && f.recurseStructPtr) {
 				continue
 			}
-			dst[f.name] = append(dst[f.name], "null")
+			dst[key] = append(dst[key], f.null)
 			continue
 		}
 
 		if f.isStruct {
-			if err := e.encode(fieldValue, dst); err != nil {
+			if err := e.encode(fieldValue, f.nestedPrefix(prefix, key), dst); err != nil {
 				errs = setError(errs, fieldValue.Type().String(), err)
 			}
 			continue
 		}
 
-		// A non-slice field with no encoder (map, chan, array, or a non-nil
-		// pointer to an unencodable type), or a slice whose element type is
-		// itself unencodable and not a pointer (e.g. []Struct), cannot be
+		if f.structElems {
+			errs = e.encodeStructSlice(f, fieldValue, key, dst, errs)
+			continue
+		}
+
+		if f.mapEnc != nil {
+			errs = e.encodeMap(f.mapEnc, fieldValue, key, dst, errs)
+			continue
+		}
+
+		// A non-slice field with no encoder (chan, or a non-nil pointer to an
+		// unencodable type), or a slice whose element type is itself
+		// unencodable and not a pointer (e.g. []complex128), cannot be
 		// encoded — historically this errored unconditionally.
-		if fieldValue.Kind() != reflect.Slice || (f.elemEnc == nil && !f.elemPtrNil) {
+		if (fieldValue.Kind() != reflect.Slice && fieldValue.Kind() != reflect.Array) || (f.elemEnc == nil && !f.elemPtrNil) {
 			errs = setError(errs, fieldValue.Type().String(), fmt.Errorf("schema: encoder not found for %v", fieldValue))
 			continue
 		}
 
-		// Encode a slice. An empty slice has nothing to encode, so it is
-		// skipped under omitempty (and otherwise emitted empty).
-		n := fieldValue.Len()
-		if n == 0 && f.omitEmpty {
+		// Encode a slice or an array. An empty slice (or an array of zero
+		// values) is skipped under omitempty; otherwise it is emitted as is.
+		if f.omitEmpty && isZero(fieldValue) {
 			continue
 		}
+		n := fieldValue.Len()
 
 		values := make([]string, n)
 		if f.elemEnc == nil {
@@ -273,7 +637,7 @@ func (e *Encoder) encode(v reflect.Value, dst map[string][]string) error {
 			bad := false
 			for j := 0; j < n; j++ {
 				if fieldValue.Index(j).IsNil() {
-					values[j] = "null"
+					values[j] = f.null
 					continue
 				}
 				errs = setError(errs, fieldValue.Type().String(), fmt.Errorf("schema: encoder not found for %v", fieldValue))
@@ -284,11 +648,16 @@ func (e *Encoder) encode(v reflect.Value, dst map[string][]string) error {
 				continue
 			}
 		} else {
-			for j := 0; j < n; j++ {
-				values[j] = f.elemEnc(fieldValue.Index(j))
+			var err error
+			for j := 0; j < n && err == nil; j++ {
+				values[j], err = f.elemEnc(fieldValue.Index(j))
+			}
+			if err != nil {
+				errs = setError(errs, key, err)
+				continue
 			}
 		}
-		dst[f.name] = values
+		dst[e.sliceKey(key)] = values
 	}
 
 	if len(errs) > 0 {
@@ -297,6 +666,117 @@ func (e *Encoder) encode(v reflect.Value, dst map[string][]string) error {
 	return nil
 }
 
+// encodeStructSlice encodes every element of a slice-of-structs field under
+// "key.N", the indexed paths the decoder reads back. A nil pointer element
+// encodes as "key.N=null", like a nil struct pointer field, unless the field
+// is omitempty. Empty slices have no elements to address and emit nothing.
+func (e *Encoder) encodeStructSlice(f *encField, sv reflect.Value, key string, dst map[string][]string, errs MultiError) MultiError {
+	if sv.Kind() == reflect.Ptr {
+		// Nil pointers were handled by nilAsNull.
+		sv = sv.Elem()
+	}
+	for j := 0; j < sv.Len(); j++ {
+		elem := sv.Index(j)
+		elemKey := e.joinPath(key, utils.FormatInt(int64(j)))
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				if !f.omitEmpty {
+					dst[elemKey] = append(dst[elemKey], f.null)
+				}
+				continue
+			}
+			elem = elem.Elem()
+		}
+		if err := e.encode(elem, elemKey, dst); err != nil {
+			errs = setError(errs, elem.Type().String(), err)
+		}
+	}
+	return errs
+}
+
+// encodeMap encodes every entry of a map field under "key.<entry key>",
+// recursing into struct values. A nil pointer to a
+// struct value encodes as "null", like a nil struct pointer field. Empty
+// maps have no entries to address and emit nothing.
+func (e *Encoder) encodeMap(m *encMap, mv reflect.Value, key string, dst map[string][]string, errs MultiError) MultiError {
+	if mv.Kind() == reflect.Ptr {
+		// Nil pointers were handled by nilAsNull.
+		mv = mv.Elem()
+	}
+	iter := mv.MapRange()
+	for iter.Next() {
+		k, err := m.keyEnc(iter.Key())
+		if err != nil {
+			errs = setError(errs, key, err)
+			continue
+		}
+		entryKey := e.joinPath(key, k)
+		val := iter.Value()
+		switch {
+		case m.valEnc != nil:
+			s, err := m.valEnc(val)
+			if err != nil {
+				errs = setError(errs, entryKey, err)
+				continue
+			}
+			dst[entryKey] = append(dst[entryKey], s)
+		case m.elemEnc != nil:
+			values := make([]string, val.Len())
+			for j := 0; j < len(values) && err == nil; j++ {
+				values[j], err = m.elemEnc(val.Index(j))
+			}
+			if err != nil {
+				errs = setError(errs, entryKey, err)
+				continue
+			}
+			dst[e.sliceKey(entryKey)] = values
+		default:
+			if val.Kind() == reflect.Ptr {
+				if val.IsNil() {
+					dst[entryKey] = append(dst[entryKey], m.null)
+					continue
+				}
+				val = val.Elem()
+			}
+			if err := e.encode(val, entryKey, dst); err != nil {
+				errs = setError(errs, val.Type().String(), err)
+			}
+		}
+	}
+	return errs
+}
+
+// nestedPrefix returns the path under which the fields of the struct held by
+// f are encoded: embedded structs are flattened into the parent's namespace
+// (prefix), named ones are addressed through the field's own key.
+func (f *encField) nestedPrefix(prefix, key string) string {
+	if f.isAnonymous {
+		return prefix
+	}
+	return key
+}
+
+// joinPath returns the key of the path segment name below prefix, in dotted
+// ("prefix.name") or bracket ("prefix[name]") notation.
+func (e *Encoder) joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	if e.bracketNotation {
+		return prefix + "[" + name + "]"
+	}
+	return prefix + "." + name
+}
+
+// sliceKey returns the key multi-valued slices are encoded under: key itself
+// in dotted notation, "key[]" in bracket notation.
+func (e *Encoder) sliceKey(key string) string {
+	if e.bracketNotation {
+		return key + "[]"
+	}
+	return key
+}
+
 // setError lazily allocates m and stores err under key, overwriting any
 // previous entry (matching the historical encoder error semantics).
 func setError(m MultiError, key string, err error) MultiError {
@@ -312,10 +792,30 @@ func (e *Encoder) hasCustomEncoder(t reflect.Type) bool {
 	return exists
 }
 
-func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc) encoderFunc {
+// typeEncoder returns the encoder for type t: a registered encoder, then the
+// builtin time.Time (in layout) and time.Duration encoders, then an
+// encoding.TextMarshaler implementation (with a value or pointer receiver),
+// then the builtin encoder of its kind. It returns nil when t cannot be
+// encoded.
+func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc, layout, null string) encoderFunc {
 	if f, ok := reg[t]; ok {
 		return f
 	}
+	switch t {
+	case timeType:
+		return timeEncoder(layout)
+	case durationType:
+		return encodeDuration
+	}
+	if t.Implements(textMarshalerType) && !(t.Kind() == reflect.Ptr && isTimeType(t.Elem())) {
+		if t.Kind() == reflect.Ptr {
+			return textMarshalerPtrEncoder(null)
+		}
+		return encodeTextMarshaler
+	}
+	if t.Kind() != reflect.Ptr && reflect.PointerTo(t).Implements(textMarshalerType) {
+		return encodeAddrTextMarshaler
+	}
 
 	switch t.Kind() {
 	case reflect.Bool:
@@ -329,16 +829,16 @@ func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc) encoderFunc {
 	case reflect.Float64:
 		return encodeFloat64
 	case reflect.Ptr:
-		f := typeEncoder(t.Elem(), reg)
+		f := typeEncoder(t.Elem(), reg, layout, null)
 		if f == nil {
 			// No encoder for the element: report unsupported instead of
 			// returning a closure that would panic on non-nil values.
 			// Nil handling for such fields is done by encField.nilAsNull.
 			return nil
 		}
-		return func(v reflect.Value) string {
+		return func(v reflect.Value) (string, error) {
 			if v.IsNil() {
-				return "null"
+				return null, nil
 			}
 			return f(v.Elem())
 		}
@@ -349,30 +849,70 @@ func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc) encoderFunc {
 	}
 }
 
-func encodeBool(v reflect.Value) string {
-	return strconv.FormatBool(v.Bool())
+func encodeBool(v reflect.Value) (string, error) {
+	return strconv.FormatBool(v.Bool()), nil
 }
 
-func encodeInt(v reflect.Value) string {
-	return utils.FormatInt(v.Int())
+func encodeInt(v reflect.Value) (string, error) {
+	return utils.FormatInt(v.Int()), nil
 }
 
-func encodeUint(v reflect.Value) string {
-	return utils.FormatUint(v.Uint())
+func encodeUint(v reflect.Value) (string, error) {
+	return utils.FormatUint(v.Uint()), nil
 }
 
 func encodeFloat(v reflect.Value, bits int) string {
 	return strconv.FormatFloat(v.Float(), 'f', 6, bits)
 }
 
-func encodeFloat32(v reflect.Value) string {
-	return encodeFloat(v, 32)
+func encodeFloat32(v reflect.Value) (string, error) {
+	return encodeFloat(v, 32), nil
+}
+
+func encodeFloat64(v reflect.Value) (string, error) {
+	return encodeFloat(v, 64), nil
 }
 
-func encodeFloat64(v reflect.Value) string {
-	return encodeFloat(v, 64)
+func encodeString(v reflect.Value) (string, error) {
+	return v.String(), nil
 }
 
-func encodeString(v reflect.Value) string {
-	return v.String()
+// encodeTextMarshaler encodes a value whose type implements
+// encoding.TextMarshaler.
+func encodeTextMarshaler(v reflect.Value) (string, error) {
+	if !v.CanInterface() {
+		return "", fmt.Errorf("schema: cannot call MarshalText on unexported field of type %v", v.Type())
+	}
+	tm, _ := reflect.TypeAssert[encoding.TextMarshaler](v)
+	text, err := tm.MarshalText()
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+// textMarshalerPtrEncoder returns the encoder of pointers implementing
+// encoding.TextMarshaler, encoding nil as null like other pointers.
+func textMarshalerPtrEncoder(null string) encoderFunc {
+	return func(v reflect.Value) (string, error) {
+		if v.IsNil() {
+			return null, nil
+		}
+		return encodeTextMarshaler(v)
+	}
+}
+
+// encodeAddrTextMarshaler encodes a value whose pointer type implements
+// encoding.TextMarshaler. Values that are not addressable (fields of a
+// struct passed by value) are copied to call the method.
+func encodeAddrTextMarshaler(v reflect.Value) (string, error) {
+	if v.CanAddr() {
+		return encodeTextMarshaler(v.Addr())
+	}
+	if !v.CanInterface() {
+		return encodeTextMarshaler(v)
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return encodeTextMarshaler(p)
 }
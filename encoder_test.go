@@ -1,8 +1,14 @@
 package schema
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -63,7 +69,7 @@ func TestFilled(t *testing.T) {
 	valExists(t, "f07", "seven", vals)
 	valExists(t, "f08", "8", vals)
 	valExists(t, "f09", "1.618000", vals)
-	valExists(t, "F12", "12", vals)
+	valExists(t, "F11.F12", "12", vals)
 
 	emptyErr := MultiError{}
 	if errs.Error() == emptyErr.Error() {
@@ -401,7 +407,8 @@ func TestEncoderWithOmitempty(t *testing.T) {
 	valNotExists(t, "f04", vals)
 	valNotExists(t, "f05", vals)
 	valNotExists(t, "f06", vals)
-	valExists(t, "f0601", "test", vals)
+	valNotExists(t, "f06.f0601", vals)
+	valExists(t, "f07.f0601", "test", vals)
 	valNotExists(t, "f08", vals)
 	valsExist(t, "f09", []string{"test"}, vals)
 }
@@ -423,7 +430,7 @@ func TestStructPointer(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to encode: %v", err)
 	}
-	valExists(t, "F12", "2", vals)
+	valExists(t, "F01.F12", "2", vals)
 	valExists(t, "F02", "null", vals)
 	valNotExists(t, "F03", vals)
 }
@@ -826,7 +833,7 @@ func TestEncoderReconfigureDuringEncode(t *testing.T) {
 // the nested type's name.
 func TestEncoderNestedErrors(t *testing.T) {
 	type Bad struct {
-		M map[string]string `schema:"m"`
+		C complex128 `schema:"c"`
 	}
 	type S struct {
 		V Bad  `schema:"v"`
@@ -834,7 +841,7 @@ func TestEncoderNestedErrors(t *testing.T) {
 	}
 	enc := NewEncoder()
 	dst := map[string][]string{}
-	err := enc.Encode(S{V: Bad{M: map[string]string{"a": "b"}}, P: &Bad{}}, dst)
+	err := enc.Encode(S{V: Bad{C: 1}, P: &Bad{}}, dst)
 	if err == nil {
 		t.Fatal("expected error for unsupported nested field")
 	}
@@ -898,15 +905,17 @@ func TestEncodeStructPointerClassification(t *testing.T) {
 		SV: Inner{X: "from-val"},
 		IP: &seven,
 	}
-	// SP and SV both recurse and write under the inner field's alias; SV is
-	// encoded after SP overwrote nothing (values append under "x").
+	// SP and SV both recurse and write under their own dotted prefixes.
 	dst := map[string][]string{}
 	if err := NewEncoder().Encode(src, dst); err != nil {
 		t.Fatal(err)
 	}
 
-	if got := dst["x"]; len(got) != 2 || got[0] != "from-ptr" || got[1] != "from-val" {
-		t.Errorf("struct pointer/value recursion: expected [from-ptr from-val] under \"x\", got %v", got)
+	if got := dst["sp.x"]; len(got) != 1 || got[0] != "from-ptr" {
+		t.Errorf("struct pointer recursion: expected [from-ptr] under \"sp.x\", got %v", got)
+	}
+	if got := dst["sv.x"]; len(got) != 1 || got[0] != "from-val" {
+		t.Errorf("struct value recursion: expected [from-val] under \"sv.x\", got %v", got)
 	}
 	if _, ok := dst["sp"]; ok {
 		t.Error("valid struct pointer must recurse, not encode under its own alias")
@@ -938,12 +947,12 @@ func TestEncodeNilDstReturnsError(t *testing.T) {
 // nil pointers keep encoding as "null".
 func TestEncodePointerToUnsupported(t *testing.T) {
 	type S struct {
-		M *map[string]string `schema:"m"`
-		L *[]int             `schema:"l"`
-		A string             `schema:"a"`
+		M *complex128 `schema:"m"`
+		L *[]int      `schema:"l"`
+		A string      `schema:"a"`
 	}
 
-	m := map[string]string{"k": "v"}
+	m := complex128(1)
 	l := []int{1}
 	dst := map[string][]string{}
 	err := NewEncoder().Encode(S{M: &m, L: &l, A: "x"}, dst)
@@ -960,7 +969,7 @@ func TestEncodePointerToUnsupported(t *testing.T) {
 		t.Fatal(err)
 	}
 	if got := dst["m"]; len(got) != 1 || got[0] != "null" {
-		t.Errorf("nil *map: expected [null], got %v", dst["m"])
+		t.Errorf("nil *complex128: expected [null], got %v", dst["m"])
 	}
 	if got := dst["l"]; len(got) != 1 || got[0] != "null" {
 		t.Errorf("nil *slice: expected [null], got %v", dst["l"])
@@ -968,7 +977,7 @@ func TestEncodePointerToUnsupported(t *testing.T) {
 
 	// omitempty suppresses the null.
 	type SO struct {
-		M *map[string]string `schema:"m,omitempty"`
+		M *complex128 `schema:"m,omitempty"`
 	}
 	dst = map[string][]string{}
 	if err := NewEncoder().Encode(SO{}, dst); err != nil {
@@ -994,13 +1003,11 @@ func TestEncodeRecoversEncoderPanic(t *testing.T) {
 	}
 }
 
-// An empty slice whose element type has no encoder (e.g. []*Struct) must be
+// An empty slice whose element type has no encoder (e.g. []*complex128) must be
 // skipped under omitempty and emitted empty otherwise, not spuriously error;
 // only a non-empty such slice errors. Non-slice unencodable fields still error.
 func TestEncodeEmptySliceUnencodableElem(t *testing.T) {
-	type Inner struct {
-		A string `schema:"a"`
-	}
+	type Inner complex128
 
 	// omitempty + nil slice -> skipped, no error.
 	type SOmit struct {
@@ -1052,37 +1059,655 @@ func TestEncodeEmptySliceUnencodableElem(t *testing.T) {
 
 	// non-empty slice of unencodable element -> error.
 	dst = map[string][]string{}
-	err := NewEncoder().Encode(SPlain{Children: []*Inner{{A: "x"}}, Name: "bob"}, dst)
+	err := NewEncoder().Encode(SPlain{Children: []*Inner{new(Inner)}, Name: "bob"}, dst)
 	if err == nil || !strings.Contains(err.Error(), "encoder not found") {
 		t.Errorf("non-empty unencodable-elem slice should error, got %v", err)
 	}
 
-	// []*Struct with nil elements encodes each nil as "null" (as historically),
-	// matching a scalar nil *Struct; a non-nil element is an error.
+	// Pointer elements with no encoder encode each nil as "null" (as
+	// historically); a non-nil element is an error.
 	dst = map[string][]string{}
 	if err := NewEncoder().Encode(SPlain{Children: []*Inner{nil, nil}, Name: "bob"}, dst); err != nil {
-		t.Fatalf("[]*Struct nil elements should encode as null, got %v", err)
+		t.Fatalf("[]*Inner nil elements should encode as null, got %v", err)
 	}
 	if got := dst["children"]; len(got) != 2 || got[0] != "null" || got[1] != "null" {
 		t.Errorf("nil elements: expected [null null], got %v", got)
 	}
 
-	// A value-struct slice ([]Struct) is unencodable and errors even when empty.
+	// A value slice of an unencodable type errors even when empty.
 	type SVal struct {
 		Items []Inner `schema:"items"`
 	}
 	if err := NewEncoder().Encode(SVal{}, map[string][]string{}); err == nil {
-		t.Errorf("empty []Struct (value) should error, matching historical behavior")
+		t.Errorf("empty []Inner (value) should error, matching historical behavior")
+	}
+
+	// non-slice unencodable field (chan) still errors regardless of emptiness.
+	type SChan struct {
+		C chan int `schema:"c"`
+	}
+	if err := NewEncoder().Encode(SChan{}, map[string][]string{}); err == nil {
+		t.Errorf("nil chan field should still error 'encoder not found'")
 	}
+	if err := NewEncoder().Encode(SChan{C: make(chan int)}, map[string][]string{}); err == nil {
+		t.Errorf("non-nil chan field should error 'encoder not found'")
+	}
+}
 
-	// non-slice unencodable field (map) still errors regardless of emptiness.
-	type SMap struct {
-		M map[string]string `schema:"m"`
+// Nested structs must be encoded under the dotted paths the decoder parses,
+// while embedded structs stay flattened, so Encode output decodes back.
+func TestEncodeNestedStructRoundTrip(t *testing.T) {
+	type Phone struct {
+		Label  string `schema:"label"`
+		Number string `schema:"number"`
+	}
+	type Base struct {
+		ID int `schema:"id"`
+	}
+	type Person struct {
+		Base
+		Name  string `schema:"name"`
+		Phone Phone  `schema:"phone"`
+		Work  *Phone `schema:"work"`
 	}
-	if err := NewEncoder().Encode(SMap{}, map[string][]string{}); err == nil {
-		t.Errorf("empty map field should still error 'encoder not found'")
+
+	src := Person{
+		Base:  Base{ID: 7},
+		Name:  "jane",
+		Phone: Phone{Label: "home", Number: "123"},
+		Work:  &Phone{Label: "office", Number: "456"},
+	}
+	dst := map[string][]string{}
+	if err := NewEncoder().Encode(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	valExists(t, "id", "7", dst)
+	valExists(t, "phone.label", "home", dst)
+	valExists(t, "phone.number", "123", dst)
+	valExists(t, "work.label", "office", dst)
+	valNotExists(t, "label", dst)
+	valNotExists(t, "Base.id", dst)
+
+	var got Person
+	if err := NewDecoder().Decode(&got, dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(src, got) {
+		t.Errorf("round trip: expected %+v, got %+v", src, got)
+	}
+}
+
+// Slices of structs encode every element under indexed "alias.N." paths, the
+// same shape the decoder parses, with nil pointer elements encoding as
+// "null" unless the field is omitempty.
+func TestEncodeSliceOfStructs(t *testing.T) {
+	type Phone struct {
+		Label string `schema:"label"`
+		Dial  []int  `schema:"dial,omitempty"`
+	}
+	type Group struct {
+		Phones []Phone `schema:"phones"`
+	}
+	type S struct {
+		Phones  []Phone  `schema:"phones"`
+		Ptrs    []*Phone `schema:"ptrs"`
+		Omitted []*Phone `schema:"omitted,omitempty"`
+		PSlice  *[]Phone `schema:"pslice"`
+		NilPS   *[]Phone `schema:"nilps"`
+		Empty   []Phone  `schema:"empty"`
+		Nested  []Group  `schema:"nested"`
+	}
+
+	src := S{
+		Phones:  []Phone{{Label: "a", Dial: []int{1, 2}}, {Label: "b"}},
+		Ptrs:    []*Phone{nil, {Label: "c"}},
+		Omitted: []*Phone{nil, {Label: "d"}},
+		PSlice:  &[]Phone{{Label: "e"}},
+		Nested:  []Group{{Phones: []Phone{{Label: "f"}}}},
+	}
+	dst := map[string][]string{}
+	if err := NewEncoder().Encode(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	valExists(t, "phones.0.label", "a", dst)
+	valsExist(t, "phones.0.dial", []string{"1", "2"}, dst)
+	valExists(t, "phones.1.label", "b", dst)
+	valNotExists(t, "phones.1.dial", dst)
+	valExists(t, "ptrs.0", "null", dst)
+	valExists(t, "ptrs.1.label", "c", dst)
+	valNotExists(t, "omitted.0", dst)
+	valExists(t, "omitted.1.label", "d", dst)
+	valExists(t, "pslice.0.label", "e", dst)
+	valExists(t, "nilps", "null", dst)
+	valNotExists(t, "empty", dst)
+	valExists(t, "nested.0.phones.0.label", "f", dst)
+
+	// Everything but the nil markers decodes back.
+	delete(dst, "ptrs.0")
+	delete(dst, "nilps")
+	var got S
+	if err := NewDecoder().Decode(&got, dst); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Phones) != 2 || got.Phones[0].Label != "a" || !reflect.DeepEqual(got.Phones[0].Dial, []int{1, 2}) || got.Phones[1].Label != "b" {
+		t.Errorf("phones: got %+v", got.Phones)
+	}
+	if len(got.Ptrs) != 2 || got.Ptrs[1] == nil || got.Ptrs[1].Label != "c" {
+		t.Errorf("ptrs: got %+v", got.Ptrs)
+	}
+	if got.PSlice == nil || len(*got.PSlice) != 1 || (*got.PSlice)[0].Label != "e" {
+		t.Errorf("pslice: got %+v", got.PSlice)
+	}
+	if len(got.Nested) != 1 || len(got.Nested[0].Phones) != 1 || got.Nested[0].Phones[0].Label != "f" {
+		t.Errorf("nested: got %+v", got.Nested)
+	}
+}
+
+// Map fields encode one "alias.key" entry per map key, recursing into struct
+// values, so the decoder reads them back.
+func TestEncodeMap(t *testing.T) {
+	type Size struct {
+		W int `schema:"w"`
+	}
+	type S struct {
+		Attrs  map[string]string `schema:"attrs"`
+		Counts map[int][]int     `schema:"counts"`
+		Sizes  map[string]Size   `schema:"sizes"`
+		Ptrs   *map[string]*Size `schema:"ptrs"`
+		Empty  map[string]string `schema:"empty,omitempty"`
+	}
+
+	src := S{
+		Attrs:  map[string]string{"color": "red", "a.b": "dotted"},
+		Counts: map[int][]int{3: {1, 2}},
+		Sizes:  map[string]Size{"small": {W: 1}},
+		Ptrs:   &map[string]*Size{"big": {W: 9}, "none": nil},
+	}
+	dst := map[string][]string{}
+	if err := NewEncoder().Encode(src, dst); err != nil {
+		t.Fatal(err)
 	}
-	if err := NewEncoder().Encode(SMap{M: map[string]string{"k": "v"}}, map[string][]string{}); err == nil {
-		t.Errorf("non-empty map field should error 'encoder not found'")
+
+	valExists(t, "attrs.color", "red", dst)
+	valExists(t, "attrs.a.b", "dotted", dst)
+	valsExist(t, "counts.3", []string{"1", "2"}, dst)
+	valExists(t, "sizes.small.w", "1", dst)
+	valExists(t, "ptrs.big.w", "9", dst)
+	valExists(t, "ptrs.none", "null", dst)
+	valNotExists(t, "empty", dst)
+
+	delete(dst, "ptrs.none")
+	var got S
+	if err := NewDecoder().Decode(&got, dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Attrs, src.Attrs) || !reflect.DeepEqual(got.Counts, src.Counts) || !reflect.DeepEqual(got.Sizes, src.Sizes) {
+		t.Errorf("round trip: expected %+v, got %+v", src, got)
+	}
+	if got.Ptrs == nil || (*got.Ptrs)["big"] == nil || (*got.Ptrs)["big"].W != 9 {
+		t.Errorf("ptrs: got %+v", got.Ptrs)
+	}
+}
+
+func TestEncodeBracketNotation(t *testing.T) {
+	type Phone struct {
+		Label string `schema:"label"`
+	}
+	type S struct {
+		Name   string              `schema:"name"`
+		Tags   []string            `schema:"tags"`
+		Phones []Phone             `schema:"phones"`
+		Home   Phone               `schema:"home"`
+		Attrs  map[string][]string `schema:"attrs"`
+	}
+
+	src := S{
+		Name:   "jane",
+		Tags:   []string{"a", "b"},
+		Phones: []Phone{{Label: "x"}},
+		Home:   Phone{Label: "y"},
+		Attrs:  map[string][]string{"color": {"red", "blue"}},
+	}
+	enc := NewEncoder()
+	enc.BracketNotation(true)
+	dst := map[string][]string{}
+	if err := enc.Encode(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	valExists(t, "name", "jane", dst)
+	valsExist(t, "tags[]", []string{"a", "b"}, dst)
+	valExists(t, "phones[0][label]", "x", dst)
+	valExists(t, "home[label]", "y", dst)
+	valsExist(t, "attrs[color][]", []string{"red", "blue"}, dst)
+
+	dec := NewDecoder()
+	dec.BracketNotation(true)
+	var got S
+	if err := dec.Decode(&got, dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(src, got) {
+		t.Errorf("round trip: expected %+v, got %+v", src, got)
+	}
+}
+
+// Arrays encode like slices: scalars as repeated values, structs under
+// indexed paths.
+func TestEncodeArrays(t *testing.T) {
+	type Point struct {
+		X int `schema:"x"`
+	}
+	type S struct {
+		Ints   [3]int    `schema:"ints"`
+		Points [2]Point  `schema:"points"`
+		Zero   [2]string `schema:"zero,omitempty"`
+	}
+
+	src := S{Ints: [3]int{1, 0, 3}, Points: [2]Point{{X: 4}, {X: 5}}}
+	dst := map[string][]string{}
+	if err := NewEncoder().Encode(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	valsExist(t, "ints", []string{"1", "0", "3"}, dst)
+	valExists(t, "points.0.x", "4", dst)
+	valExists(t, "points.1.x", "5", dst)
+	valNotExists(t, "zero", dst)
+
+	var got S
+	if err := NewDecoder().Decode(&got, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got != src {
+		t.Errorf("round trip: expected %+v, got %+v", src, got)
+	}
+}
+
+func TestEncodeValues(t *testing.T) {
+	type S struct {
+		Name string `schema:"name"`
+		Age  int    `schema:"age"`
+	}
+	enc := NewEncoder()
+
+	vals, err := EncodeValues(enc, S{Name: "jane", Age: 30})
+	noError(t, err)
+	if got := vals.Get("name"); got != "jane" {
+		t.Errorf("expected name jane, got %q", got)
+	}
+	valExists(t, "age", "30", vals)
+
+	vals, err = EncodeValues(enc, &S{Name: "joe"})
+	noError(t, err)
+	valExists(t, "name", "joe", vals)
+
+	if _, err := EncodeValues(enc, 1); err != errNotStruct {
+		t.Errorf("expected errNotStruct, got %v", err)
+	}
+	if _, err := EncodeValues[*S](enc, nil); err != errNotStruct {
+		t.Errorf("expected errNotStruct for a nil pointer, got %v", err)
+	}
+}
+
+type textID int
+
+func (id textID) MarshalText() ([]byte, error) {
+	if id < 0 {
+		return nil, errors.New("negative id")
+	}
+	return []byte("id-" + strconv.Itoa(int(id))), nil
+}
+
+type textPtrID struct{ n int }
+
+func (id *textPtrID) MarshalText() ([]byte, error) {
+	return []byte("p-" + strconv.Itoa(id.n)), nil
+}
+
+func TestEncodeTextMarshaler(t *testing.T) {
+	type S struct {
+		ID      textID               `schema:"id"`
+		PtrID   textPtrID            `schema:"ptr_id"`
+		Nil     *textID              `schema:"nil"`
+		IDs     []textID             `schema:"ids"`
+		PtrIDs  []textPtrID          `schema:"ptr_ids"`
+		Arr     [2]textPtrID         `schema:"arr"`
+		IP      net.IP               `schema:"ip"`
+		Addr    netip.Addr           `schema:"addr"`
+		ByID    map[textID]textPtrID `schema:"by"`
+		Omitted *textPtrID           `schema:"omitted,omitempty"`
+	}
+	src := S{
+		ID:     1,
+		PtrID:  textPtrID{2},
+		IDs:    []textID{3, 4},
+		PtrIDs: []textPtrID{{5}},
+		Arr:    [2]textPtrID{{6}, {7}},
+		IP:     net.ParseIP("10.0.0.1"),
+		Addr:   netip.MustParseAddr("::1"),
+		ByID:   map[textID]textPtrID{8: {9}},
+	}
+
+	// Pointer receivers work whether or not the source is addressable.
+	for _, v := range []interface{}{src, &src} {
+		vals := map[string][]string{}
+		noError(t, NewEncoder().Encode(v, vals))
+		valExists(t, "id", "id-1", vals)
+		valExists(t, "ptr_id", "p-2", vals)
+		valExists(t, "nil", "null", vals)
+		valsExist(t, "ids", []string{"id-3", "id-4"}, vals)
+		valExists(t, "ptr_ids", "p-5", vals)
+		valsExist(t, "arr", []string{"p-6", "p-7"}, vals)
+		valExists(t, "ip", "10.0.0.1", vals)
+		valExists(t, "addr", "::1", vals)
+		valExists(t, "by.id-8", "p-9", vals)
+		valNotExists(t, "omitted", vals)
+	}
+}
+
+func TestEncodeTextMarshalerPrecedence(t *testing.T) {
+	type S struct {
+		ID textID `schema:"id"`
+	}
+	enc := NewEncoder()
+	enc.RegisterEncoder(textID(0), func(v reflect.Value) string {
+		return "registered"
+	})
+	vals := map[string][]string{}
+	noError(t, enc.Encode(S{ID: 1}, vals))
+	valExists(t, "id", "registered", vals)
+}
+
+func TestEncodeTextMarshalerError(t *testing.T) {
+	type S struct {
+		ID   textID            `schema:"id"`
+		IDs  []textID          `schema:"ids"`
+		ByID map[string]textID `schema:"by"`
+		OK   textID            `schema:"ok"`
+	}
+	vals := map[string][]string{}
+	err := NewEncoder().Encode(S{ID: -1, IDs: []textID{1, -2}, ByID: map[string]textID{"a": -3}, OK: 4}, vals)
+	var multi MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	for _, key := range []string{"id", "ids", "by.a"} {
+		if multi[key] == nil || multi[key].Error() != "negative id" {
+			t.Errorf("expected the marshal error under %q, got %v", key, multi[key])
+		}
+		valNotExists(t, key, vals)
+	}
+	valExists(t, "ok", "id-4", vals)
+}
+
+func TestEncodeTime(t *testing.T) {
+	type S struct {
+		At      time.Time            `schema:"at"`
+		Date    time.Time            `schema:"date,layout:2006-01-02"`
+		Ptr     *time.Time           `schema:"ptr,layout:15:04"`
+		Nil     *time.Time           `schema:"nil"`
+		Dates   []time.Time          `schema:"dates,layout:2006-01-02"`
+		Zero    time.Time            `schema:"zero,omitempty"`
+		Timeout time.Duration        `schema:"timeout"`
+		ByName  map[string]time.Time `schema:"by,layout:2006-01-02"`
+	}
+	at := time.Date(2024, 3, 1, 10, 20, 30, 5e8, time.UTC)
+	src := S{
+		At:      at,
+		Date:    at,
+		Ptr:     &at,
+		Dates:   []time.Time{at, at.AddDate(0, 0, 1)},
+		Timeout: 90 * time.Second,
+		ByName:  map[string]time.Time{"a": at},
+	}
+	vals := map[string][]string{}
+	noError(t, NewEncoder().Encode(src, vals))
+	valExists(t, "at", "2024-03-01T10:20:30.5Z", vals)
+	valExists(t, "date", "2024-03-01", vals)
+	valExists(t, "ptr", "10:20", vals)
+	valExists(t, "nil", "null", vals)
+	valsExist(t, "dates", []string{"2024-03-01", "2024-03-02"}, vals)
+	valNotExists(t, "zero", vals)
+	valExists(t, "timeout", "1m30s", vals)
+	valExists(t, "by.a", "2024-03-01", vals)
+
+	type R struct {
+		At      time.Time     `schema:"at"`
+		Date    time.Time     `schema:"date,layout:2006-01-02"`
+		Timeout time.Duration `schema:"timeout"`
+	}
+	var got R
+	if err := NewDecoder().Decode(&got, map[string][]string{"at": vals["at"], "date": vals["date"], "timeout": vals["timeout"]}); err != nil {
+		t.Fatal(err)
+	}
+	if !got.At.Equal(at) || !got.Date.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || got.Timeout != src.Timeout {
+		t.Errorf("round trip: unexpected %+v", got)
+	}
+}
+
+func TestEncoderSetTimeLayout(t *testing.T) {
+	type S struct {
+		At     time.Time `schema:"at"`
+		Tagged time.Time `schema:"tagged,layout:15:04"`
+	}
+	at := time.Date(2024, 3, 1, 10, 20, 0, 0, time.UTC)
+	enc := NewEncoder()
+	vals := map[string][]string{}
+	noError(t, enc.Encode(S{At: at, Tagged: at}, vals))
+	valExists(t, "at", "2024-03-01T10:20:00Z", vals)
+
+	enc.SetTimeLayout("2006-01-02")
+	vals = map[string][]string{}
+	noError(t, enc.Encode(S{At: at, Tagged: at}, vals))
+	valExists(t, "at", "2024-03-01", vals)
+	valExists(t, "tagged", "10:20", vals)
+}
+
+func TestEncoderReportAliasConflicts(t *testing.T) {
+	encoder := NewEncoder()
+	vals := map[string][]string{}
+	if err := encoder.Encode(conflictForm{Code: "code"}, vals); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	encoder.ReportAliasConflicts(true)
+	vals = map[string][]string{}
+	err := encoder.Encode(&conflictForm{Code: "code"}, vals)
+	var errs MultiError
+	// Keys are encoded as is: only "id" is shared, not "name" and "Name".
+	if !errors.As(err, &errs) || len(errs) != 1 || errs["schema.conflictForm.id"] == nil {
+		t.Fatalf("expected the conflicts, got %v", err)
+	}
+	valsLength(t, 0, vals)
+
+	type plain struct {
+		Name string `schema:"name"`
+	}
+	vals = map[string][]string{}
+	noError(t, encoder.Encode(plain{Name: "x"}, vals))
+	valExists(t, "name", "x", vals)
+}
+
+// Fields promoted from embedded structs follow the dominance rules of the
+// decoder: shadowed and ambiguous ones are not encoded.
+func TestEncoderAliasDominance(t *testing.T) {
+	type Base struct {
+		ID     int        `schema:"id"`
+		Name   string     `schema:"name"`
+		Amount complex128 `schema:"amount"`
+		Note   string     `schema:"note"`
+	}
+	type Other struct {
+		ID int `schema:"id"`
+	}
+	type Form struct {
+		Base
+		*Other
+		Name   string `schema:"name"`
+		Amount string `schema:"amount"`
+	}
+	src := Form{Base: Base{ID: 1, Name: "inner", Amount: 1i, Note: "n"}, Other: &Other{ID: 2}, Name: "outer", Amount: "3"}
+
+	encoder := NewEncoder()
+	encoder.RegisterEncoder(complex128(0), func(v reflect.Value) string {
+		return strconv.FormatComplex(v.Complex(), 'f', -1, 128)
+	})
+	vals := map[string][]string{}
+	noError(t, encoder.Encode(src, vals))
+	valExists(t, "name", "outer", vals)
+	valExists(t, "amount", "3", vals)
+	valExists(t, "note", "n", vals)
+	valNotExists(t, "id", vals)
+	valsLength(t, 3, vals)
+
+	// The decoder drops Base.Amount, of a type it cannot decode, but the
+	// encoder reports the key it shares with Form.Amount.
+	encoder.ReportAliasConflicts(true)
+	err := encoder.Encode(src, map[string][]string{})
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected the conflicts, got %v", err)
+	}
+	want := []string{"schema.Form.amount", "schema.Form.id", "schema.Form.name"}
+	if got := errs.Paths(); !slices.Equal(got, want) {
+		t.Fatalf("expected conflicts %v, got %v", want, errs.FullError())
+	}
+	var conflict AliasConflictError
+	if !errors.As(errs["schema.Form.amount"], &conflict) || conflict.Dominant != "Amount" ||
+		!slices.Equal(conflict.Fields, []string{"Base.Amount", "Amount"}) {
+		t.Errorf("unexpected conflict %+v", conflict)
+	}
+	if !errors.As(errs["schema.Form.id"], &conflict) || conflict.Dominant != "" {
+		t.Errorf("unexpected conflict %+v", conflict)
+	}
+}
+
+func TestEncoderSetNullValue(t *testing.T) {
+	type inner struct {
+		N int `schema:"n"`
+	}
+	type S struct {
+		I     *int               `schema:"i"`
+		Inner *inner             `schema:"inner"`
+		Items []*inner           `schema:"items"`
+		ByKey map[string]*inner  `schema:"by"`
+		Text  *netip.Addr        `schema:"text"`
+		Extra map[string]*string `schema:"extra"`
+	}
+	s := S{Items: []*inner{nil}, ByKey: map[string]*inner{"k": nil}, Extra: map[string]*string{"k": nil}}
+
+	encoder := NewEncoder()
+	encoder.SetNullValue("nil")
+	vals := map[string][]string{}
+	noError(t, encoder.Encode(s, vals))
+	for _, key := range []string{"i", "inner", "items.0", "by.k", "text", "extra.k"} {
+		valExists(t, key, "nil", vals)
+	}
+
+	encoder.SetNullValue("")
+	vals = map[string][]string{}
+	noError(t, encoder.Encode(s, vals))
+	valExists(t, "i", "null", vals)
+}
+
+func TestEncoderOptional(t *testing.T) {
+	type S struct {
+		Age  Optional[int]        `schema:"age"`
+		Nick Optional[string]     `schema:"nick"`
+		Gone Optional[int]        `schema:"gone"`
+		Addr Optional[netip.Addr] `schema:"addr"`
+		Tags Optional[[]string]   `schema:"tags"`
+		When Optional[time.Time]  `schema:"when,layout:2006-01-02"`
+	}
+	s := S{
+		Age:  Some(0),
+		Nick: Optional[string]{Present: true, Null: true},
+		Addr: Some(netip.MustParseAddr("10.0.0.1")),
+		Tags: Some([]string{"a", "b"}),
+		When: Some(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	encoder := NewEncoder()
+	vals := map[string][]string{}
+	noError(t, encoder.Encode(s, vals))
+	valsLength(t, 5, vals)
+	valExists(t, "age", "0", vals)
+	valExists(t, "nick", "null", vals)
+	valExists(t, "addr", "10.0.0.1", vals)
+	valExists(t, "when", "2024-05-01", vals)
+	if !reflect.DeepEqual(vals["tags"], []string{"a", "b"}) {
+		t.Errorf("tags: expected [a b], got %v", vals["tags"])
+	}
+
+	decoder := NewDecoder()
+	decoder.SetNullValue("null")
+	var dst S
+	if err := decoder.Decode(&dst, vals); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(s, dst) {
+		t.Errorf("expected %+v, got %+v", s, dst)
+	}
+}
+
+func TestEncoderSQLNullTypes(t *testing.T) {
+	type S struct {
+		Name  sql.NullString  `schema:"name"`
+		Age   sql.NullInt64   `schema:"age"`
+		Admin sql.NullBool    `schema:"admin,omitempty"`
+		Since sql.NullTime    `schema:"since,layout:2006-01-02"`
+		Level sql.Null[int16] `schema:"level"`
+	}
+	s := S{
+		Name:  sql.NullString{String: "Ann", Valid: true},
+		Since: sql.NullTime{Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+		Level: sql.Null[int16]{V: 2, Valid: true},
+	}
+
+	encoder := NewEncoder()
+	vals := map[string][]string{}
+	noError(t, encoder.Encode(s, vals))
+	valsLength(t, 4, vals)
+	valExists(t, "name", "Ann", vals)
+	valExists(t, "age", "", vals)
+	valExists(t, "since", "2024-05-01", vals)
+	valExists(t, "level", "2", vals)
+
+	// Invalid values decode back with any null value, or none.
+	for _, null := range []string{"", "null"} {
+		decoder := NewDecoder()
+		decoder.SetNullValue(null)
+		dst := S{Age: sql.NullInt64{Int64: 3, Valid: true}}
+		if err := decoder.Decode(&dst, vals); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(s, dst) {
+			t.Errorf("expected %+v, got %+v", s, dst)
+		}
+	}
+}
+
+type encEnumLevel int
+
+func TestRegisterEnumEncoder(t *testing.T) {
+	type S struct {
+		Level  encEnumLevel   `schema:"level"`
+		Levels []encEnumLevel `schema:"levels"`
+		Bad    encEnumLevel   `schema:"bad"`
+	}
+	encoder := NewEncoder()
+	RegisterEnumEncoder(encoder, map[string]encEnumLevel{"low": 1, "high": 2, "max": 2})
+
+	vals := map[string][]string{}
+	err := encoder.Encode(S{Level: 1, Levels: []encEnumLevel{2, 1}, Bad: 3}, vals)
+	var errs MultiError
+	if !errors.As(err, &errs) || errs["bad"] == nil {
+		t.Fatalf("expected an error for bad, got %v", err)
+	}
+	valExists(t, "level", "low", vals)
+	if !reflect.DeepEqual(vals["levels"], []string{"high", "low"}) {
+		t.Errorf("levels: expected [high low], got %v", vals["levels"])
 	}
 }
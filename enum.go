@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	utilstrings "github.com/gofiber/utils/v2/strings"
+)
+
+// Enum describes an enum type registered with RegisterEnum.
+type Enum struct {
+	Type reflect.Type
+	// Names are the names of the values of the enum, sorted.
+	Names []string
+	// Values maps the names to the values.
+	Values map[string]any
+	// CaseInsensitive makes the names match regardless of case.
+	CaseInsensitive bool
+}
+
+// EnumOption configures an enum registered with RegisterEnum.
+type EnumOption func(*Enum)
+
+// EnumCaseInsensitive is an EnumOption matching the names of the enum
+// regardless of case.
+func EnumCaseInsensitive(e *Enum) {
+	e.CaseInsensitive = true
+}
+
+// EnumValueError is the error of a ConversionError for a value that is not
+// a name of its enum type.
+type EnumValueError struct {
+	Value   string   // the value decoded.
+	Allowed []string // the names of the enum, sorted.
+}
+
+func (e EnumValueError) Error() string {
+	return fmt.Sprintf("%q is not one of %s", e.Value, strings.Join(e.Allowed, ", "))
+}
+
+// RegisterEnum registers a converter for the enum type T, decoding the
+// names of values to their value. Other values are reported as
+// ConversionErrors with an EnumValueError listing the names.
+//
+//	schema.RegisterEnum(decoder, map[string]Status{
+//		"active":   StatusActive,
+//		"disabled": StatusDisabled,
+//	}, schema.EnumCaseInsensitive)
+func RegisterEnum[T comparable](d *Decoder, values map[string]T, opts ...EnumOption) {
+	enum := Enum{
+		Type:   reflect.TypeFor[T](),
+		Names:  slices.Sorted(maps.Keys(values)),
+		Values: make(map[string]any, len(values)),
+	}
+	for name, value := range values {
+		enum.Values[name] = value
+	}
+	for _, opt := range opts {
+		opt(&enum)
+	}
+	lookup := maps.Clone(values)
+	if enum.CaseInsensitive {
+		// Of the names equal regardless of case, the first one sorted wins.
+		lookup = make(map[string]T, len(values))
+		for _, name := range enum.Names {
+			key := utilstrings.ToLower(name)
+			if _, ok := lookup[key]; !ok {
+				lookup[key] = values[name]
+			}
+		}
+	}
+	d.cache.registerEnum(enum, func(s string) (reflect.Value, error) {
+		key := s
+		if enum.CaseInsensitive {
+			key = utilstrings.ToLower(s)
+		}
+		v, ok := lookup[key]
+		if !ok {
+			return invalidValue, EnumValueError{Value: s, Allowed: slices.Clone(enum.Names)}
+		}
+		return reflect.ValueOf(&v).Elem(), nil
+	})
+}
+
+// Enums returns the enums registered with RegisterEnum, sorted by type.
+func (d *Decoder) Enums() []Enum {
+	d.cache.l.RLock()
+	enums := make([]Enum, 0, len(d.cache.enums))
+	for _, enum := range d.cache.enums {
+		enum.Names = slices.Clone(enum.Names)
+		enum.Values = maps.Clone(enum.Values)
+		enums = append(enums, enum)
+	}
+	d.cache.l.RUnlock()
+	slices.SortFunc(enums, func(a, b Enum) int {
+		return strings.Compare(a.Type.String(), b.Type.String())
+	})
+	return enums
+}
+
+// RegisterEnumEncoder registers an encoder for the enum type T, encoding
+// values as their names in values; of several names of a value, the first
+// one sorted. Encoding a value without a name is an error.
+func RegisterEnumEncoder[T comparable](e *Encoder, values map[string]T) {
+	t := reflect.TypeFor[T]()
+	names := make(map[T]string, len(values))
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if _, ok := names[values[name]]; !ok {
+			names[values[name]] = name
+		}
+	}
+	e.registerEncoder(t, func(v reflect.Value) (string, error) {
+		if !v.CanInterface() {
+			return "", fmt.Errorf("schema: cannot encode unexported field of type %v", t)
+		}
+		value, _ := reflect.TypeAssert[T](v)
+		if name, ok := names[value]; ok {
+			return name, nil
+		}
+		return "", fmt.Errorf("schema: %v is not a value of enum %v", value, t)
+	})
+}
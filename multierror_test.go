@@ -2,6 +2,8 @@ package schema
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -24,8 +26,8 @@ func TestMultiErrorError(t *testing.T) {
 	if !strings.HasSuffix(out, "(and 1 other error)") {
 		t.Fatalf("unexpected output %q", out)
 	}
-	if !strings.HasPrefix(out, errA.Error()) && !strings.HasPrefix(out, errB.Error()) {
-		t.Fatalf("unexpected prefix %q", out)
+	if !strings.HasPrefix(out, errA.Error()) {
+		t.Fatalf("expected the error of the smallest path first, got %q", out)
 	}
 
 	errC := errors.New("c")
@@ -36,6 +38,97 @@ func TestMultiErrorError(t *testing.T) {
 	}
 }
 
+func TestMultiErrorDeterministic(t *testing.T) {
+	m := MultiError{"z": errors.New("z"), "b.1": errors.New("b1"), "a": errors.New("a"), "b.0": errors.New("b0")}
+	for i := 0; i < 20; i++ {
+		if got := m.Error(); got != "a (and 3 other errors)" {
+			t.Fatalf("unexpected output %q", got)
+		}
+	}
+
+	// Errors with empty messages don't hide the smallest path.
+	m2 := MultiError{"a": errors.New(""), "b": errors.New("b"), "c": errors.New("c")}
+	for i := 0; i < 20; i++ {
+		if got := m2.Error(); got != " (and 2 other errors)" {
+			t.Fatalf("unexpected output %q", got)
+		}
+	}
+
+	want := []string{"a", "b.0", "b.1", "z"}
+	if got := m.Paths(); !slices.Equal(got, want) {
+		t.Fatalf("Paths: expected %v, got %v", want, got)
+	}
+
+	fieldErrs := m.Errors()
+	if len(fieldErrs) != len(want) {
+		t.Fatalf("Errors: expected %d errors, got %d", len(want), len(fieldErrs))
+	}
+	for i, fe := range fieldErrs {
+		if fe.Path != want[i] || fe.Err != m[want[i]] {
+			t.Errorf("Errors[%d]: expected %q, got %+v", i, want[i], fe)
+		}
+		if !errors.Is(fe, m[want[i]]) {
+			t.Errorf("Errors[%d]: expected FieldError to unwrap to its error", i)
+		}
+	}
+
+	var paths []string
+	for path, err := range m.All() {
+		if err != m[path] {
+			t.Errorf("All: unexpected error for %q", path)
+		}
+		paths = append(paths, path)
+		if len(paths) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(paths, want[:2]) {
+		t.Fatalf("All: expected %v, got %v", want[:2], paths)
+	}
+
+	full := "a: a\nb.0: b0\nb.1: b1\nz: z"
+	if got := m.FullError(); got != full {
+		t.Fatalf("FullError: expected %q, got %q", full, got)
+	}
+	if got := (MultiError{}).FullError(); got != "(0 errors)" {
+		t.Fatalf("FullError: expected (0 errors), got %q", got)
+	}
+}
+
+func TestMultiErrorUnwrap(t *testing.T) {
+	type S struct {
+		N   int    `schema:"n"`
+		Req string `schema:"req,required"`
+	}
+	var s S
+	err := NewDecoder().Decode(&s, map[string][]string{"n": {"x"}, "extra": {"1"}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var conv ConversionError
+	if !errors.As(err, &conv) || conv.Key != "n" {
+		t.Errorf("expected errors.As to reach the ConversionError, got %v", conv)
+	}
+	var empty EmptyFieldError
+	if !errors.As(err, &empty) || empty.Key != "req" {
+		t.Errorf("expected errors.As to reach the EmptyFieldError, got %v", empty)
+	}
+	var unknown UnknownKeyError
+	if !errors.As(err, &unknown) || unknown.Key != "extra" {
+		t.Errorf("expected errors.As to reach the UnknownKeyError, got %v", unknown)
+	}
+
+	sentinel := errors.New("sentinel")
+	m := MultiError{"a": errors.New("a"), "b": fmt.Errorf("wrapped: %w", sentinel)}
+	if !errors.Is(m, sentinel) {
+		t.Error("expected errors.Is to reach a wrapped error")
+	}
+	if errs := m.Unwrap(); len(errs) != 2 || errs[0] != m["a"] || errs[1] != m["b"] {
+		t.Errorf("expected errors sorted by path, got %v", errs)
+	}
+}
+
 func TestMergeErrors(t *testing.T) {
 	errA := errors.New("a")
 	m1 := MultiError{"a": errA}
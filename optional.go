@@ -0,0 +1,60 @@
+package schema
+
+import "reflect"
+
+// Optional is a field value that tells a key absent from the source apart
+// from a key holding the zero value or the null value, without a pointer.
+//
+// The Decoder sets Present when the source has the key, and Null when its
+// value is the null value set with Decoder.SetNullValue (or empty, with
+// Decoder.EmptyAsNull); otherwise Value is decoded like a field of type T,
+// with registered converters, encoding.TextUnmarshaler and the builtin
+// conversions. T cannot be a struct decoded field by field, a slice of such
+// structs or a map. The required and default options apply to Optional
+// fields as to fields of type T; a default value makes the field present.
+//
+// The Encoder omits absent Optional fields, and encodes null ones as its
+// null value.
+type Optional[T any] struct {
+	Value   T
+	Present bool
+	Null    bool
+}
+
+// Some returns a present Optional holding v.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{Value: v, Present: true}
+}
+
+// Get returns the value of o, and whether it is present and not null.
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Present && !o.Null
+}
+
+func (Optional[T]) optional() {}
+
+// optionalMarker is implemented by the Optional types only.
+type optionalMarker interface {
+	optional()
+}
+
+var optionalMarkerType = reflect.TypeFor[optionalMarker]()
+
+// Indices of the fields of Optional.
+const (
+	optionalValue = iota
+	optionalPresent
+	optionalNull
+)
+
+// isOptionalType reports whether t is an Optional type.
+func isOptionalType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.Implements(optionalMarkerType)
+}
+
+// setOptional sets v, an Optional, to a present value, null or not. The
+// value itself is left to the caller.
+func setOptional(v reflect.Value, null bool) {
+	v.Field(optionalPresent).SetBool(true)
+	v.Field(optionalNull).SetBool(null)
+}
@@ -0,0 +1,21 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Indices of the fields of the database/sql Null types.
+const (
+	sqlNullValue = iota
+	sqlNullValid
+)
+
+// isSQLNullType reports whether t is one of the Null types of database/sql,
+// like sql.NullString or sql.Null[T]: a struct of a value and a Valid flag,
+// decoded and encoded as a nullable value.
+func isSQLNullType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.PkgPath() == "database/sql" &&
+		strings.HasPrefix(t.Name(), "Null") && t.NumField() == 2 &&
+		t.Field(sqlNullValid).Name == "Valid" && t.Field(sqlNullValid).Type.Kind() == reflect.Bool
+}
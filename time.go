@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+)
+
+// defaultTimeLayouts are tried in order to decode a time.Time when neither
+// the field nor the decoder sets a layout: RFC 3339 (with or without
+// fractional seconds) and the formats of the HTML datetime-local, date and
+// time inputs.
+var defaultTimeLayouts = [...]string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"15:04:05",
+	"15:04",
+}
+
+// isTimeType reports whether t is decoded and encoded by the builtin time
+// support.
+func isTimeType(t reflect.Type) bool {
+	return t == timeType || t == durationType
+}
+
+// parseTime parses s with layout, or with the first of the default layouts
+// that matches when layout is empty. The error of the first layout is
+// reported when none matches.
+func parseTime(layout, s string) (time.Time, error) {
+	if layout != "" {
+		return time.Parse(layout, s)
+	}
+	var first error
+	for _, l := range defaultTimeLayouts {
+		t, err := time.Parse(l, s)
+		if err == nil {
+			return t, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return time.Time{}, first
+}
+
+// parseDuration parses s as a Go duration ("1h30m"), or as an integer
+// number of nanoseconds, the representation of a time.Duration.
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err == nil {
+		return d, nil
+	}
+	if n, nerr := strconv.ParseInt(s, 10, 64); nerr == nil {
+		return time.Duration(n), nil
+	}
+	return 0, err
+}
+
+// convertTime converts s to a value of t, time.Time or time.Duration. A
+// time is parsed with layout, the decoder-wide layout, or the default
+// layouts, in that order of precedence.
+func (d *Decoder) convertTime(t reflect.Type, layout, s string) (reflect.Value, error) {
+	if t == durationType {
+		dur, err := parseDuration(s)
+		if err != nil {
+			return invalidValue, err
+		}
+		return reflect.ValueOf(dur), nil
+	}
+	if layout == "" {
+		layout = d.timeLayout
+	}
+	tm, err := parseTime(layout, s)
+	if err != nil {
+		return invalidValue, err
+	}
+	return reflect.ValueOf(tm), nil
+}
+
+// timeEncoder returns the encoder for time.Time values in layout, or in
+// RFC 3339 with fractional seconds when layout is empty.
+func timeEncoder(layout string) encoderFunc {
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	return func(v reflect.Value) (string, error) {
+		if !v.CanInterface() {
+			return "", fmt.Errorf("schema: cannot encode unexported field of type %v", v.Type())
+		}
+		t, _ := reflect.TypeAssert[time.Time](v)
+		return t.Format(layout), nil
+	}
+}
+
+func encodeDuration(v reflect.Value) (string, error) {
+	return time.Duration(v.Int()).String(), nil
+}
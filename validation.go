@@ -0,0 +1,781 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	utils "github.com/gofiber/utils/v2"
+)
+
+// fieldRules holds the validation rules of a field, compiled from its tag
+// options once per type. Size rules (min, max, len) apply to the length of
+// strings and collections and to the value of numbers; value rules (oneof,
+// pattern, email, url) apply to scalars and to each element of slices and
+// arrays.
+type fieldRules struct {
+	min, max  float64
+	hasMin    bool
+	hasMax    bool
+	length    int
+	hasLength bool
+	minParam  string
+	maxParam  string
+	oneof     []any // normalized by ruleValue
+	oneofRaw  string
+	pattern   *regexp.Regexp
+	email     bool
+	url       bool
+	// err reports the rules that could not be compiled or do not apply to
+	// the field type; the other rules are checked, and a value passing them
+	// is refused with err.
+	err error
+}
+
+// isRuleOption reports whether a tag option is a validation rule.
+func isRuleOption(opt string) bool {
+	if opt == "email" || opt == "url" {
+		return true
+	}
+	name, _, ok := strings.Cut(opt, ":")
+	if !ok {
+		return false
+	}
+	switch name {
+	case "min", "max", "len", "oneof", "pattern":
+		return true
+	}
+	return false
+}
+
+// hasRuleOption reports whether the options declare a validation rule.
+func (o tagOptions) hasRuleOption() bool {
+	if o == "" {
+		return false
+	}
+	for s := range strings.SplitSeq(string(o), ",") {
+		if isRuleOption(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileRules compiles the validation rules of a field of type t, returning
+// nil when its options declare none.
+func compileRules(options tagOptions, t reflect.Type) *fieldRules {
+	if !options.hasRuleOption() {
+		return nil
+	}
+	r := &fieldRules{}
+	t = indirectType(t)
+	elemT := t
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		elemT = indirectType(t.Elem())
+	}
+	for opt := range strings.SplitSeq(string(options), ",") {
+		if !isRuleOption(opt) {
+			continue
+		}
+		if err := r.compile(opt, t, elemT); err != nil {
+			r.err = errors.Join(r.err, fmt.Errorf("schema: invalid validation rule %q for type %v: %w", opt, t, err))
+		}
+	}
+	return r
+}
+
+func (r *fieldRules) compile(opt string, t, elemT reflect.Type) error {
+	name, param, _ := strings.Cut(opt, ":")
+	switch name {
+	case "min", "max", "len":
+		if !hasSize(t) {
+			return fmt.Errorf("%s only applies to strings, numbers, slices, arrays and maps", name)
+		}
+		if name == "len" {
+			if k := t.Kind(); k != reflect.String && k != reflect.Slice && k != reflect.Array && k != reflect.Map {
+				return fmt.Errorf("len only applies to strings, slices, arrays and maps")
+			}
+			n, err := strconv.Atoi(param)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%q is not a length", param)
+			}
+			r.length, r.hasLength = n, true
+			return nil
+		}
+		f, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return err
+		}
+		if name == "min" {
+			r.min, r.hasMin, r.minParam = f, true, param
+		} else {
+			r.max, r.hasMax, r.maxParam = f, true, param
+		}
+	case "oneof":
+		r.oneofRaw = param
+		for s := range strings.SplitSeq(param, "|") {
+			v, err := parseRuleValue(elemT.Kind(), s)
+			if err != nil {
+				return err
+			}
+			r.oneof = append(r.oneof, v)
+		}
+	case "pattern":
+		if elemT.Kind() != reflect.String {
+			return fmt.Errorf("pattern only applies to strings")
+		}
+		re, err := regexp.Compile(param)
+		if err != nil {
+			return err
+		}
+		r.pattern = re
+	case "email", "url":
+		if elemT.Kind() != reflect.String {
+			return fmt.Errorf("%s only applies to strings", name)
+		}
+		if name == "email" {
+			r.email = true
+		} else {
+			r.url = true
+		}
+	}
+	return nil
+}
+
+// hasSize reports whether the min, max and len rules apply to type t.
+func hasSize(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// parseRuleValue parses a oneof value for kind k, normalized like ruleValue.
+func parseRuleValue(k reflect.Kind, s string) (any, error) {
+	switch k {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(s, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	}
+	return nil, fmt.Errorf("oneof only applies to strings, numbers and bools")
+}
+
+// ruleValue returns the value of v normalized to string, bool, int64,
+// uint64 or float64 for oneof comparisons.
+func ruleValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return nil
+}
+
+// check validates v, the value of a field at path. Zero values are not
+// checked, since the required option rejects missing values, except the
+// zero numbers of fields provided by the source, as reported by provided.
+// A value passing the rules is still refused when a rule of the field is
+// invalid, since it cannot be checked against it.
+func (r *fieldRules) check(path string, v reflect.Value, provided func() bool) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.IsZero() && !(isNumberKind(v.Kind()) && provided()) {
+		return nil
+	}
+	if err := r.checkRules(path, v); err != nil {
+		return err
+	}
+	return r.err
+}
+
+// checkRules checks the compiled rules against v, the non-zero value of a
+// field at path.
+func (r *fieldRules) checkRules(path string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		if err := r.checkSize(path, float64(utf8.RuneCountInString(v.String())), " characters long", "must be"); err != nil {
+			return err
+		}
+		return r.checkValue(path, -1, v)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if err := r.checkSize(path, float64(v.Len()), " elements", "must have"); err != nil {
+			return err
+		}
+		if v.Kind() == reflect.Map {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i)
+			if elem.Kind() == reflect.Ptr {
+				if elem.IsNil() {
+					continue
+				}
+				elem = elem.Elem()
+			}
+			if elem.IsZero() && !isNumberKind(elem.Kind()) {
+				continue
+			}
+			if err := r.checkValue(path, i, elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if err := r.checkSize(path, float64(v.Int()), "", "must be"); err != nil {
+			return err
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if err := r.checkSize(path, float64(v.Uint()), "", "must be"); err != nil {
+			return err
+		}
+	case reflect.Float32, reflect.Float64:
+		if err := r.checkSize(path, v.Float(), "", "must be"); err != nil {
+			return err
+		}
+	}
+	return r.checkValue(path, -1, v)
+}
+
+// isNumberKind reports whether k is the kind of a number.
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// checkSize checks the min, max and len rules against n, the length of a
+// string or collection or the value of a number. verb and unit phrase the
+// failure, as in "must be at least 3 characters long".
+func (r *fieldRules) checkSize(path string, n float64, unit, verb string) error {
+	switch {
+	case r.hasLength && n != float64(r.length):
+		param := utils.FormatInt(int64(r.length))
+		return ValidationError{Key: path, Rule: "len", Param: param, Index: -1,
+			reason: verb + " exactly " + param + unit}
+	case r.hasMin && n < r.min:
+		return ValidationError{Key: path, Rule: "min", Param: r.minParam, Index: -1,
+			reason: verb + " at least " + r.minParam + unit}
+	case r.hasMax && n > r.max:
+		return ValidationError{Key: path, Rule: "max", Param: r.maxParam, Index: -1,
+			reason: verb + " at most " + r.maxParam + unit}
+	}
+	return nil
+}
+
+// checkValue checks the oneof, pattern, email and url rules against v, a
+// scalar or the element at index of a collection.
+func (r *fieldRules) checkValue(path string, index int, v reflect.Value) error {
+	if r.oneof != nil {
+		value := ruleValue(v)
+		found := false
+		for _, allowed := range r.oneof {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ValidationError{Key: path, Rule: "oneof", Param: r.oneofRaw, Index: index,
+				reason: "must be one of " + strings.ReplaceAll(r.oneofRaw, "|", ", ")}
+		}
+	}
+	if v.Kind() != reflect.String {
+		return nil
+	}
+	s := v.String()
+	if r.pattern != nil && !r.pattern.MatchString(s) {
+		return ValidationError{Key: path, Rule: "pattern", Param: r.pattern.String(), Index: index,
+			reason: "must match " + r.pattern.String()}
+	}
+	if r.email {
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return ValidationError{Key: path, Rule: "email", Index: index, reason: "must be an email address"}
+		}
+	}
+	if r.url {
+		if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+			return ValidationError{Key: path, Rule: "url", Index: index, reason: "must be an absolute URL"}
+		}
+	}
+	return nil
+}
+
+// Validator is implemented by types that validate themselves. Decode calls
+// Validate on the destination and on the structs nested in it (fields,
+// slice elements and map values) after decoding, setting defaults and
+// checking required fields.
+//
+// A returned MultiError is merged with its keys taken as paths relative to
+// the struct; any other error is reported under the path of the struct,
+// which is "" for the destination itself.
+type Validator interface {
+	Validate() error
+}
+
+var validatorType = reflect.TypeFor[Validator]()
+
+// walkedNode is a field, or a nested struct, reached by walk.
+type walkedNode struct {
+	field  *fieldInfo // nil for a nested struct (slice element, map value)
+	value  reflect.Value
+	path   string // form path, like "items.0.sku"
+	goPath string // Go path, like "Items[0].SKU"
+}
+
+// walk calls visit for every field of struct v, described by info, and
+// recursively for the structs held by them: struct fields, elements of
+// slices of structs and values of maps of structs, which are also visited
+// themselves as nodes without a field. prefix and goPrefix are the paths of
+// v ending with a separator, or empty at the root. It stops when visit
+// returns false.
+func (d *Decoder) walk(info *structInfo, v reflect.Value, prefix, goPrefix string, visit func(walkedNode) bool) bool {
+	for _, f := range info.fields {
+		if f.isAnonymous && indirectType(f.typ).Kind() == reflect.Struct {
+			// Promoted fields are visited through their copies in info.
+			continue
+		}
+		fv, ok := fieldByIndexChain(v, f.index)
+		if !ok {
+			continue
+		}
+		node := walkedNode{field: f, value: fv, path: prefix + f.alias, goPath: goPrefix + f.goPath}
+		if !visit(node) {
+			return false
+		}
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		switch {
+		case f.isMapOfStructs:
+			iter := fv.MapRange()
+			for iter.Next() {
+				key := fmt.Sprint(iter.Key().Interface())
+				if !d.walkStruct(iter.Value(), node.path+"."+key, node.goPath+"["+key+"]", visit) {
+					return false
+				}
+			}
+		case f.isSliceOfStructs && !f.elemUnmarshaler.IsValid:
+			for i := 0; i < fv.Len(); i++ {
+				idx := utils.FormatInt(int64(i))
+				if !d.walkStruct(fv.Index(i), node.path+"."+idx, node.goPath+"["+idx+"]", visit) {
+					return false
+				}
+			}
+		case f.isStruct:
+			if !d.walk(d.cache.get(fv.Type()), fv, node.path+".", node.goPath+".", visit) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// walkStruct visits v, an element of a slice or a value of a map, as a node
+// and walks its fields when it is a struct or a non-nil pointer to one.
+func (d *Decoder) walkStruct(v reflect.Value, path, goPath string, visit func(walkedNode) bool) bool {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return true
+	}
+	if !visit(walkedNode{value: v, path: path, goPath: goPath}) {
+		return false
+	}
+	return d.walk(d.cache.get(v.Type()), v, path+".", goPath+".", visit)
+}
+
+// validate checks the validation rules of the fields of struct v, described
+// by info, and of the structs nested in it, decoded from src. Failures are
+// reported under the form path of the field.
+func (d *Decoder) validate(info *structInfo, v reflect.Value, src map[string][]string, errs MultiError) MultiError {
+	// The Go paths of the fields in src, only gathered for zero numbers.
+	var provided map[string]bool
+	d.walk(info, v, "", "", func(n walkedNode) bool {
+		if n.field != nil && n.field.rules != nil {
+			value := n.value
+			if n.field.isOptional {
+				value = value.Field(optionalValue)
+			} else if n.field.isSQLNull {
+				value = value.Field(sqlNullValue)
+			}
+			isProvided := func() bool {
+				if provided == nil {
+					provided = d.providedFields(info, src)
+				}
+				return provided[n.goPath]
+			}
+			if err := n.field.rules.check(n.path, value, isProvided); err != nil {
+				errs = appendError(errs, n.path, err)
+			}
+		}
+		return true
+	})
+	return errs
+}
+
+// providedFields returns the Go paths, as in walkedNode.goPath, of the
+// fields of the struct described by info that have a key in src.
+func (d *Decoder) providedFields(info *structInfo, src map[string][]string) map[string]bool {
+	provided := make(map[string]bool, len(src))
+	for path := range src {
+		if parts, err := d.cache.parsePathInfo(path, info); err == nil {
+			provided[goFieldPath(parts)] = true
+		}
+	}
+	return provided
+}
+
+// goFieldPath returns the Go path of the field addressed by parts in the
+// form of walkedNode.goPath, like "Items[0].SKU". A path ending at an
+// element of a slice or a value of a map addresses the slice or map field.
+func goFieldPath(parts []pathPart) string {
+	var b strings.Builder
+	for i, part := range parts {
+		for _, hop := range part.hops {
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(hop.field.goPath)
+		}
+		if i == len(parts)-1 || len(parts[i+1].hops) == 0 {
+			break
+		}
+		b.WriteByte('[')
+		if part.field.isMap {
+			b.WriteString(part.mapKey)
+		} else {
+			b.WriteString(utils.FormatInt(int64(part.index)))
+		}
+		b.WriteByte(']')
+	}
+	return b.String()
+}
+
+// callValidators calls Validate on v, the destination described by info,
+// and on the structs nested in it that implement Validator.
+func (d *Decoder) callValidators(info *structInfo, v reflect.Value, errs MultiError) MultiError {
+	errs = validateStruct(v, "", errs)
+	d.walk(info, v, "", "", func(n walkedNode) bool {
+		value := n.value
+		if n.field != nil {
+			if !n.field.isStruct {
+				return true
+			}
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					return true
+				}
+				value = value.Elem()
+			}
+		}
+		errs = validateStruct(value, n.path, errs)
+		return true
+	})
+	return errs
+}
+
+// validateStruct calls Validate on v, or on its address for pointer
+// receivers, when implemented, and merges the error into errs under path.
+// Values that are not addressable (map values) are copied.
+func validateStruct(v reflect.Value, path string, errs MultiError) MultiError {
+	if v.CanAddr() {
+		v = v.Addr()
+	} else if v.CanInterface() && reflect.PointerTo(v.Type()).Implements(validatorType) {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p
+	}
+	if !v.CanInterface() || !v.Type().Implements(validatorType) {
+		return errs
+	}
+	validator, _ := reflect.TypeAssert[Validator](v)
+	return mergeValidationError(errs, validator.Validate(), func(key string) string {
+		if path == "" {
+			return key
+		}
+		if key == "" {
+			return path
+		}
+		return path + "." + key
+	})
+}
+
+// runValidator calls the validator set with SetValidator on dst, v being
+// the destination described by info, with the Go paths of its fields mapped
+// to their form paths.
+func (d *Decoder) runValidator(info *structInfo, v reflect.Value, errs MultiError) MultiError {
+	plan := d.cache.goPaths(info)
+	paths := plan.static
+	if len(plan.dynamic) > 0 {
+		paths = maps.Clone(plan.static)
+		d.expandGoPaths(plan, v, "", "", paths)
+	}
+	err := d.validator(v.Addr().Interface(), paths)
+	return mergeValidationError(errs, err, func(key string) string {
+		if path, ok := paths[key]; ok {
+			return path
+		}
+		return key
+	})
+}
+
+// goPathPlan maps the Go paths of the fields of a struct type to their form
+// paths, as passed to the validator set with SetValidator. static holds the
+// fields reached through nested structs only, and dynamic the slices and
+// maps of structs, and the recursive nested structs, whose fields are added
+// for the values of each Decode.
+type goPathPlan struct {
+	static  map[string]string
+	dynamic []goPathNode
+}
+
+// goPathNode is a field of a goPathPlan whose structs are only known from
+// the decoded value: chain leads to the field from the planned struct, and
+// elem describes its structs.
+type goPathNode struct {
+	chain          []*fieldInfo
+	goPath, path   string
+	isSlice, isMap bool
+	elem           *structInfo
+}
+
+// goPaths returns the plan of Go paths of the struct described by info,
+// built on first use.
+func (c *cache) goPaths(info *structInfo) *goPathPlan {
+	info.goPathsOnce.Do(func() {
+		plan := &goPathPlan{static: make(map[string]string)}
+		c.planGoPaths(info, plan, nil, "", "", map[*structInfo]bool{info: true})
+		info.goPathPlan = plan
+	})
+	return info.goPathPlan
+}
+
+// planGoPaths adds the fields of the struct described by info, reached
+// through chain, to plan. visited holds the structs of chain, whose
+// recursive occurrences are left to Decode.
+func (c *cache) planGoPaths(info *structInfo, plan *goPathPlan, chain []*fieldInfo, prefix, goPrefix string, visited map[*structInfo]bool) {
+	for _, f := range info.fields {
+		if f.isAnonymous && indirectType(f.typ).Kind() == reflect.Struct {
+			// Promoted fields are planned through their copies in info.
+			continue
+		}
+		path, goPath := prefix+f.alias, goPrefix+f.goPath
+		plan.static[goPath] = path
+		node := goPathNode{chain: append(slices.Clone(chain), f), goPath: goPath, path: path}
+		switch {
+		case f.isMapOfStructs:
+			node.isMap, node.elem = true, c.get(structElem(f.typ))
+			plan.dynamic = append(plan.dynamic, node)
+		case f.isSliceOfStructs && !f.elemUnmarshaler.IsValid:
+			node.isSlice, node.elem = true, c.get(structElem(f.typ))
+			plan.dynamic = append(plan.dynamic, node)
+		case f.isStruct:
+			nested := c.get(indirectType(f.typ))
+			if visited[nested] {
+				node.elem = nested
+				plan.dynamic = append(plan.dynamic, node)
+				continue
+			}
+			visited[nested] = true
+			c.planGoPaths(nested, plan, node.chain, path+".", goPath+".", visited)
+			delete(visited, nested)
+		}
+	}
+}
+
+// expandGoPaths adds to paths the Go paths of the fields of the structs of
+// the dynamic fields of plan, planned for v, under prefix and goPrefix.
+func (d *Decoder) expandGoPaths(plan *goPathPlan, v reflect.Value, prefix, goPrefix string, paths map[string]string) {
+	for _, node := range plan.dynamic {
+		fv, ok := valueByFieldChain(v, node.chain)
+		if !ok {
+			continue
+		}
+		path, goPath := prefix+node.path, goPrefix+node.goPath
+		switch {
+		case node.isMap:
+			iter := fv.MapRange()
+			for iter.Next() {
+				key := fmt.Sprint(iter.Key().Interface())
+				d.addGoPaths(node.elem, iter.Value(), path+"."+key+".", goPath+"["+key+"].", paths)
+			}
+		case node.isSlice:
+			for i := 0; i < fv.Len(); i++ {
+				idx := utils.FormatInt(int64(i))
+				d.addGoPaths(node.elem, fv.Index(i), path+"."+idx+".", goPath+"["+idx+"].", paths)
+			}
+		default:
+			d.addGoPaths(node.elem, fv, path+".", goPath+".", paths)
+		}
+	}
+}
+
+// addGoPaths adds to paths the Go paths of the fields of v, a struct
+// described by info or a pointer to one, under prefix and goPrefix.
+func (d *Decoder) addGoPaths(info *structInfo, v reflect.Value, prefix, goPrefix string, paths map[string]string) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	plan := d.cache.goPaths(info)
+	for goPath, path := range plan.static {
+		paths[goPrefix+goPath] = prefix + path
+	}
+	d.expandGoPaths(plan, v, prefix, goPrefix, paths)
+}
+
+// valueByFieldChain returns the value of the field reached through chain
+// from v, with pointers dereferenced, or false when a nil pointer blocks
+// the chain.
+func valueByFieldChain(v reflect.Value, chain []*fieldInfo) (reflect.Value, bool) {
+	for _, f := range chain {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		var ok bool
+		if v, ok = fieldByIndexChain(v, f.index); !ok {
+			return v, false
+		}
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// mergeValidationError merges err, returned by a validator, into errs: the
+// keys of a MultiError are translated with path, any other error is
+// reported under path(""). Existing errors are kept.
+func mergeValidationError(errs MultiError, err error, path func(string) string) MultiError {
+	if err == nil {
+		return errs
+	}
+	var multi MultiError
+	if !errors.As(err, &multi) {
+		return appendError(errs, path(""), err)
+	}
+	for key, e := range multi {
+		errs = appendError(errs, path(key), e)
+	}
+	return errs
+}
+
+// fieldByIndexChain is like walkIndexChain, but reports a chain blocked by a
+// nil embedded pointer instead of allocating it.
+func fieldByIndexChain(v reflect.Value, chain []int) (reflect.Value, bool) {
+	for j, fi := range chain {
+		if j > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(fi)
+	}
+	return v, true
+}
+
+// structElem returns the type of the structs held by a field of type t,
+// unwrapping pointers, slices, arrays and maps.
+func structElem(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return t
+		}
+	}
+}
+
+// hasRules reports whether a validation rule is declared anywhere in the
+// struct tree rooted at t, including the structs of slices and maps.
+// visited guards against recursive types. tag is the alias tag snapshot for
+// this build.
+func (c *cache) hasRules(t reflect.Type, tag string, visited map[reflect.Type]bool) bool {
+	t = structElem(t)
+	if t.Kind() != reflect.Struct || visited[t] {
+		return false
+	}
+	visited[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		alias, options := fieldAlias(field, tag)
+		if alias == "-" {
+			continue
+		}
+		if options.hasRuleOption() || c.hasRules(field.Type, tag, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasValidators reports whether a struct of the tree rooted at t implements
+// Validator, with a value or pointer receiver. visited guards against
+// recursive types.
+func hasValidators(t reflect.Type, visited map[reflect.Type]bool) bool {
+	t = structElem(t)
+	if t.Kind() != reflect.Struct || visited[t] {
+		return false
+	}
+	visited[t] = true
+	if reflect.PointerTo(t).Implements(validatorType) {
+		return true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if hasValidators(t.Field(i).Type, visited) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,482 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidationRules(t *testing.T) {
+	type S struct {
+		Name   string   `schema:"name,min:2,max:5"`
+		Code   string   `schema:"code,len:3"`
+		Age    int      `schema:"age,min:18,max:99"`
+		Ratio  *float64 `schema:"ratio,max:1"`
+		Color  string   `schema:"color,oneof:red|green"`
+		Level  int      `schema:"level,oneof:1|2|3"`
+		Slug   string   `schema:"slug,pattern:^[a-z-]+$"`
+		Email  string   `schema:"email,email"`
+		Site   string   `schema:"site,url"`
+		Tags   []string `schema:"tags,max:2,oneof:a|b|c"`
+		Emails []string `schema:"emails,email"`
+	}
+
+	valid := map[string][]string{
+		"name":   {"Jo"},
+		"code":   {"abc"},
+		"age":    {"30"},
+		"ratio":  {"0.5"},
+		"color":  {"red"},
+		"level":  {"2"},
+		"slug":   {"a-b"},
+		"email":  {"jo@example.com"},
+		"site":   {"https://example.com/x"},
+		"tags":   {"a", "c"},
+		"emails": {"a@example.com", "b@example.com"},
+	}
+	if err := NewDecoder().Decode(&S{}, valid); err != nil {
+		t.Fatalf("expected valid values to pass, got %v", err)
+	}
+
+	// Zero values are not checked.
+	if err := NewDecoder().Decode(&S{}, map[string][]string{}); err != nil {
+		t.Fatalf("expected missing values to pass, got %v", err)
+	}
+
+	invalid := map[string][]string{
+		"name":   {"Jonathan"},
+		"code":   {"ab"},
+		"age":    {"12"},
+		"ratio":  {"1.5"},
+		"color":  {"blue"},
+		"level":  {"4"},
+		"slug":   {"A_B"},
+		"email":  {"Jo <jo@example.com>"},
+		"site":   {"/relative"},
+		"tags":   {"a", "b", "c"},
+		"emails": {"a@example.com", "nope"},
+	}
+	err := NewDecoder().Decode(&S{}, invalid)
+	multi, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	want := map[string]string{
+		"name":   "max",
+		"code":   "len",
+		"age":    "min",
+		"ratio":  "max",
+		"color":  "oneof",
+		"level":  "oneof",
+		"slug":   "pattern",
+		"email":  "email",
+		"site":   "url",
+		"tags":   "max",
+		"emails": "email",
+	}
+	if len(multi) != len(want) {
+		t.Errorf("expected %d errors, got %d: %s", len(want), len(multi), multi.FullError())
+	}
+	for path, rule := range want {
+		var verr ValidationError
+		if !errors.As(multi[path], &verr) {
+			t.Errorf("%s: expected a ValidationError, got %v", path, multi[path])
+			continue
+		}
+		if verr.Key != path || verr.Rule != rule {
+			t.Errorf("%s: expected rule %s, got %+v", path, rule, verr)
+		}
+	}
+	if verr := multi["emails"].(ValidationError); verr.Index != 1 {
+		t.Errorf("emails: expected index 1, got %d", verr.Index)
+	}
+	if got := multi["name"].Error(); got != `schema: "name" must be at most 5 characters long` {
+		t.Errorf("unexpected message %q", got)
+	}
+	if got := multi["tags"].Error(); got != `schema: "tags" must have at most 2 elements` {
+		t.Errorf("unexpected message %q", got)
+	}
+	if got := multi["emails"].Error(); got != `schema: element 1 of "emails" must be an email address` {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+func TestValidationNestedPaths(t *testing.T) {
+	type Item struct {
+		SKU string `schema:"sku,len:4"`
+	}
+	type Address struct {
+		Zip string `schema:"zip,pattern:^[0-9]{5}$"`
+	}
+	type Embedded struct {
+		Note string `schema:"note,max:3"`
+	}
+	type S struct {
+		Embedded
+		Home   Address            `schema:"home"`
+		Work   *Address           `schema:"work"`
+		Items  []Item             `schema:"items"`
+		ByName map[string]Address `schema:"by"`
+	}
+	err := NewDecoder().Decode(&S{}, map[string][]string{
+		"note":        {"long"},
+		"home.zip":    {"1"},
+		"work.zip":    {"2"},
+		"items.0.sku": {"abcd"},
+		"items.1.sku": {"abc"},
+		"by.x.zip":    {"3"},
+	})
+	multi, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	want := []string{"by.x.zip", "home.zip", "items.1.sku", "note", "work.zip"}
+	if got := multi.Paths(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected errors for %v, got %v", want, got)
+	}
+}
+
+func TestValidationKeepsConversionErrors(t *testing.T) {
+	// A conversion error is not shadowed by a validation failure of the
+	// zero value left in the field.
+	type S struct {
+		Age int `schema:"age,min:18"`
+	}
+	err := NewDecoder().Decode(&S{}, map[string][]string{"age": {"x"}})
+	var conv ConversionError
+	if !errors.As(err, &conv) {
+		t.Fatalf("expected a ConversionError, got %v", err)
+	}
+}
+
+func TestValidationInvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  interface{}
+	}{
+		{"bad regexp", &struct {
+			F string `schema:"f,pattern:["`
+		}{}},
+		{"bad number", &struct {
+			F int `schema:"f,min:x"`
+		}{}},
+		{"len on a number", &struct {
+			F int `schema:"f,len:2"`
+		}{}},
+		{"email on a number", &struct {
+			F int `schema:"f,email"`
+		}{}},
+		{"oneof value of the wrong type", &struct {
+			F int `schema:"f,oneof:a|b"`
+		}{}},
+		{"size on a bool", &struct {
+			F bool `schema:"f,max:1"`
+		}{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			decoder := NewDecoder()
+			err := decoder.Prepare(tc.dst)
+			multi, ok := err.(MultiError)
+			key := reflect.TypeOf(tc.dst).Elem().String() + ".F"
+			if !ok || multi[key] == nil || !strings.Contains(multi[key].Error(), "invalid validation rule") {
+				t.Fatalf("expected the rule error under %s, got %v", key, err)
+			}
+			// Decode refuses the values it cannot check.
+			err = decoder.Decode(tc.dst, map[string][]string{"f": {"1"}})
+			multi, ok = err.(MultiError)
+			if !ok || multi["f"] == nil || !strings.Contains(multi["f"].Error(), "invalid validation rule") {
+				t.Errorf("expected the rule error under f, got %v", err)
+			}
+			// Missing values are not checked.
+			if err := decoder.Decode(reflect.New(reflect.TypeOf(tc.dst).Elem()).Interface(), map[string][]string{}); err != nil {
+				t.Errorf("expected no error without a value, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidationInvalidRuleBesideValidRule(t *testing.T) {
+	type S struct {
+		Email string `schema:"email,email,max:1O"`
+	}
+	decoder := NewDecoder()
+
+	// The valid rule is still checked.
+	var s S
+	err := decoder.Decode(&s, map[string][]string{"email": {"not-an-email"}})
+	var verr ValidationError
+	if !errors.As(err, &verr) || verr.Rule != "email" {
+		t.Fatalf("expected the email rule to fail, got %v", err)
+	}
+
+	// A value passing it is refused with the invalid rule.
+	err = decoder.Decode(&s, map[string][]string{"email": {"ann@example.com"}})
+	multi, ok := err.(MultiError)
+	if !ok || multi["email"] == nil || !strings.Contains(multi["email"].Error(), `invalid validation rule "max:1O"`) {
+		t.Fatalf("expected the rule error, got %v", err)
+	}
+}
+
+func TestValidationSkippedWithoutRules(t *testing.T) {
+	type Inner struct {
+		A string `schema:"a"`
+	}
+	type S struct {
+		In    Inner   `schema:"in"`
+		Items []Inner `schema:"items"`
+	}
+	d := NewDecoder()
+	if d.cache.get(reflect.TypeFor[S]()).hasRules {
+		t.Fatal("expected no rules for a tree without rule options")
+	}
+	type R struct {
+		Items []struct {
+			B string `schema:"b,max:1"`
+		} `schema:"items"`
+	}
+	if !d.cache.get(reflect.TypeFor[R]()).hasRules {
+		t.Fatal("expected rules declared in slice elements to be found")
+	}
+}
+
+type validatedItem struct {
+	SKU string `schema:"sku"`
+}
+
+func (i validatedItem) Validate() error {
+	if i.SKU == "bad" {
+		return errors.New("bad sku")
+	}
+	return nil
+}
+
+type validatedAddress struct {
+	Zip string `schema:"zip"`
+}
+
+func (a *validatedAddress) Validate() error {
+	if a.Zip == "" {
+		return MultiError{"zip": errors.New("zip is missing")}
+	}
+	return nil
+}
+
+type validatedForm struct {
+	Name  string                      `schema:"name"`
+	Home  validatedAddress            `schema:"home"`
+	Work  *validatedAddress           `schema:"work"`
+	Items []validatedItem             `schema:"items"`
+	By    map[string]validatedAddress `schema:"by"`
+}
+
+func (f *validatedForm) Validate() error {
+	if f.Name == "" {
+		return errors.New("form is incomplete")
+	}
+	return nil
+}
+
+func TestValidatorInterface(t *testing.T) {
+	d := NewDecoder()
+	var f validatedForm
+	err := d.Decode(&f, map[string][]string{
+		"home.zip":    {"12345"},
+		"work.zip":    {""},
+		"items.0.sku": {"ok"},
+		"items.1.sku": {"bad"},
+		"by.x.zip":    {""},
+	})
+	multi, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	want := map[string]string{
+		"":         "form is incomplete",
+		"work.zip": "zip is missing",
+		"items.1":  "bad sku",
+		"by.x.zip": "zip is missing",
+	}
+	if len(multi) != len(want) {
+		t.Errorf("expected %d errors, got %s", len(want), multi.FullError())
+	}
+	for path, msg := range want {
+		if multi[path] == nil || multi[path].Error() != msg {
+			t.Errorf("%q: expected %q, got %v", path, msg, multi[path])
+		}
+	}
+
+	f = validatedForm{}
+	if err := d.Decode(&f, map[string][]string{"name": {"x"}, "home.zip": {"1"}}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestValidatorInterfaceKeepsDecodeErrors(t *testing.T) {
+	type S struct {
+		Item validatedItem `schema:"item"`
+		N    int           `schema:"n"`
+	}
+	err := NewDecoder().Decode(&S{}, map[string][]string{"item.sku": {"bad"}, "n": {"x"}})
+	multi, ok := err.(MultiError)
+	if !ok || len(multi) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	if _, ok := multi["n"].(ConversionError); !ok {
+		t.Errorf("expected the ConversionError for n, got %v", multi["n"])
+	}
+	if multi["item"] == nil {
+		t.Errorf("expected the Validate error for item")
+	}
+}
+
+func TestSetValidator(t *testing.T) {
+	type Item struct {
+		SKU string `schema:"sku"`
+	}
+	type Embedded struct {
+		Note string `schema:"note"`
+	}
+	type S struct {
+		Embedded
+		Name  string          `schema:"name"`
+		Items []Item          `schema:"items"`
+		By    map[string]Item `schema:"by"`
+	}
+
+	var gotPaths map[string]string
+	var gotDst any
+	d := NewDecoder()
+	d.SetValidator(func(dst any, paths map[string]string) error {
+		gotDst, gotPaths = dst, paths
+		return MultiError{
+			"Name":         errors.New("name error"),
+			"Items[1].SKU": errors.New("sku error"),
+			"Other":        errors.New("other error"),
+		}
+	})
+	var s S
+	err := d.Decode(&s, map[string][]string{
+		"name":        {"x"},
+		"note":        {"y"},
+		"items.1.sku": {"z"},
+		"by.k.sku":    {"w"},
+	})
+
+	if gotDst != &s {
+		t.Errorf("expected the destination, got %v", gotDst)
+	}
+	wantPaths := map[string]string{
+		"Embedded.Note": "note",
+		"Name":          "name",
+		"Items":         "items",
+		"Items[0].SKU":  "items.0.sku",
+		"Items[1].SKU":  "items.1.sku",
+		"By":            "by",
+		"By[k].SKU":     "by.k.sku",
+	}
+	if !reflect.DeepEqual(gotPaths, wantPaths) {
+		t.Errorf("expected paths %v, got %v", wantPaths, gotPaths)
+	}
+
+	multi, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	for path, msg := range map[string]string{"name": "name error", "items.1.sku": "sku error", "Other": "other error"} {
+		if multi[path] == nil || multi[path].Error() != msg {
+			t.Errorf("%q: expected %q, got %v", path, msg, multi[path])
+		}
+	}
+
+	d.SetValidator(func(any, map[string]string) error { return errors.New("plain") })
+	err = d.Decode(&S{}, map[string][]string{})
+	if multi, ok := err.(MultiError); !ok || multi[""] == nil || multi[""].Error() != "plain" {
+		t.Errorf("expected the plain error under \"\", got %v", err)
+	}
+
+	d.SetValidator(nil)
+	if err := d.Decode(&S{}, map[string][]string{}); err != nil {
+		t.Errorf("expected no error without a validator, got %v", err)
+	}
+}
+
+func TestValidationProvidedZero(t *testing.T) {
+	type Item struct {
+		Qty int `schema:"qty,min:1"`
+	}
+	type Base struct {
+		Level uint `schema:"level,oneof:1|2"`
+	}
+	type S struct {
+		Base
+		Age    int     `schema:"age,min:18"`
+		Ptr    *int    `schema:"ptr,min:1"`
+		Name   string  `schema:"name,min:2"`
+		Items  []Item  `schema:"items"`
+		Scores []int   `schema:"scores,oneof:1|2"`
+		Ratio  float64 `schema:"ratio,min:0.5"`
+	}
+	decoder := NewDecoder()
+
+	// Absent fields and empty strings are not checked.
+	if err := decoder.Decode(&S{}, map[string][]string{"name": {""}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := decoder.Decode(&S{}, map[string][]string{
+		"AGE":         {"0"},
+		"ptr":         {"0"},
+		"items.1.qty": {"0"},
+		"scores":      {"1", "0"},
+		"Base.level":  {"0"},
+	})
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	want := []string{"age", "items.1.qty", "level", "ptr", "scores"}
+	if got := errs.Paths(); !slices.Equal(got, want) {
+		t.Fatalf("expected errors for %v, got %v", want, errs.FullError())
+	}
+}
+
+type validatorNode struct {
+	Name string          `schema:"name"`
+	Next *validatorNode  `schema:"next"`
+	Kids []validatorNode `schema:"kids"`
+}
+
+func TestSetValidatorRecursive(t *testing.T) {
+	var gotPaths map[string]string
+	d := NewDecoder()
+	d.SetValidator(func(_ any, paths map[string]string) error {
+		gotPaths = paths
+		return nil
+	})
+	var n validatorNode
+	err := d.Decode(&n, map[string][]string{
+		"name":             {"a"},
+		"next.name":        {"b"},
+		"next.kids.0.name": {"c"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantPaths := map[string]string{
+		"Name":              "name",
+		"Next":              "next",
+		"Kids":              "kids",
+		"Next.Name":         "next.name",
+		"Next.Next":         "next.next",
+		"Next.Kids":         "next.kids",
+		"Next.Kids[0].Name": "next.kids.0.name",
+		"Next.Kids[0].Next": "next.kids.0.next",
+		"Next.Kids[0].Kids": "next.kids.0.kids",
+	}
+	if !reflect.DeepEqual(gotPaths, wantPaths) {
+		t.Errorf("expected paths %v, got %v", wantPaths, gotPaths)
+	}
+}